@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// appendixLine is one line of the typed-fallback appendix.
+type appendixLine struct {
+	text    string
+	heading bool // category heading rather than code
+}
+
+// appendixSpacing is the line spacing of appendix text.
+const appendixSpacing = 1.4
+
+// appendixFonts returns the heading and code fonts of the appendix.
+func appendixFonts(opts Options) (heading, code fontSpec) {
+	return opts.boldFont(opts.fontSize(0, 28)), opts.monoFont(opts.fontSize(0, 24))
+}
+
+// paginateAppendix lays out every command's exact code, grouped under its
+// category, into pages that fit the printable height of the page. Lines too
+// long for the page are hard-wrapped with an indented continuation.
+func paginateAppendix(dc canvas, cmds []GitCmd, opts Options) [][]appendixLine {
+	width, height := opts.pagePx()
+	margin := opts.px(60)
+	headingFont, codeFont := appendixFonts(opts)
+
+	dc.SetFont(codeFont)
+	charWidth, _ := dc.MeasureString("M")
+	maxChars := max(int((float64(width)-2*margin)/charWidth), 8)
+	codeHeight := dc.FontHeight() * appendixSpacing
+	dc.SetFont(headingFont)
+	headingHeight := dc.FontHeight() * appendixSpacing * 1.5
+	avail := float64(height) - 2*margin - appendixTitleBand(dc, opts) - opts.headerStrip()
+
+	var pages [][]appendixLine
+	var page []appendixLine
+	used := 0.0
+	add := func(l appendixLine) {
+		h := codeHeight
+		if l.heading {
+			h = headingHeight
+		}
+		if used+h > avail && len(page) > 0 {
+			pages = append(pages, page)
+			page, used = nil, 0
+		}
+		page = append(page, l)
+		used += h
+	}
+
+	for i, cmd := range cmds {
+		if i == 0 || cmds[i-1].Category != cmd.Category {
+			category := cmd.Category
+			if category == "" {
+				category = "(uncategorised)"
+			}
+			add(appendixLine{text: category, heading: true})
+		}
+		for _, line := range splitLines(cmd.Code) {
+			for _, chunk := range hardWrap(line, maxChars) {
+				add(appendixLine{text: chunk})
+			}
+		}
+	}
+	if len(page) > 0 {
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// hardWrap splits s into chunks of at most n characters, indenting the
+// continuation chunks by two spaces so they read as one line.
+func hardWrap(s string, n int) []string {
+	r := []rune(s)
+	if len(r) <= n {
+		return []string{s}
+	}
+	chunks := []string{string(r[:n])}
+	for r = r[n:]; len(r) > 0; {
+		k := min(len(r), n-2)
+		chunks = append(chunks, "  "+string(r[:k]))
+		r = r[k:]
+	}
+	return chunks
+}
+
+// appendixTitleBand is the height taken by an appendix page's title.
+func appendixTitleBand(dc canvas, opts Options) float64 {
+	dc.SetFont(opts.font(opts.fontSize(opts.TitleSize, 36)))
+	return dc.FontHeight() * 2
+}
+
+// drawAppendixPage draws page n of total of the appendix onto dc.
+func drawAppendixPage(dc canvas, lines []appendixLine, n, total int, opts Options) {
+	width, _ := opts.pagePx()
+	margin := opts.px(60)
+	headingFont, codeFont := appendixFonts(opts)
+
+	if _, err := drawHeaderStrip(dc, opts.headerStrip(), opts); err != nil {
+		log.Printf("QR error for header: %v", err)
+	}
+	top := opts.headerStrip()
+
+	dc.SetColor(color.Black)
+	title := "Commands to type"
+	if total > 1 {
+		title = fmt.Sprintf("%s (%d/%d)", title, n, total)
+	}
+	band := appendixTitleBand(dc, opts)
+	dc.DrawStringAnchored(title, float64(width)/2, top+margin/2+band/2, 0.5, 0.5)
+
+	y := top + margin + band
+	for _, l := range lines {
+		if l.heading {
+			dc.SetFont(headingFont)
+			y += dc.FontHeight() * appendixSpacing * 0.5
+			dc.DrawStringAnchored(l.text, margin, y, 0, 1)
+			y += dc.FontHeight() * appendixSpacing
+			continue
+		}
+		dc.SetFont(codeFont)
+		dc.DrawStringAnchored(l.text, margin, y, 0, 1)
+		y += dc.FontHeight() * appendixSpacing
+	}
+}
+
+// renderAppendix draws the appendix pages for cmds as raster pages.
+func renderAppendix(cmds []GitCmd, opts Options) ([]*gg.Context, error) {
+	if err := opts.checkPixels(); err != nil {
+		return nil, err
+	}
+	width, height := opts.pagePx()
+	pages := paginateAppendix(&rasterCanvas{gg.NewContext(1, 1)}, cmds, opts)
+
+	var out []*gg.Context
+	for i, lines := range pages {
+		dc := gg.NewContext(width, height)
+		dc.SetRGB(1, 1, 1)
+		dc.Clear()
+		drawAppendixPage(&rasterCanvas{dc}, lines, i+1, len(pages), opts)
+		out = append(out, dc)
+	}
+	return out, nil
+}
+
+// pageFileName returns the file for page n of a multi-page raster output:
+// the first page is out itself, later ones get "-n" before the extension.
+func pageFileName(out string, n int) string {
+	if n <= 1 {
+		return out
+	}
+	ext := filepath.Ext(out)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(out, ext), n, ext)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// canvas is the surface a sheet is drawn on. Coordinates are page pixels at
+// Options.DPI: the raster canvas draws them as is, the PDF canvas converts
+// them to points. Text is measured with the same Go Regular faces on every
+// canvas so layout is identical across outputs.
+type canvas interface {
+	SetColor(c color.Color)
+	SetLineWidth(w float64)
+	SetDash(dashes ...float64)
+	SetFont(f fontSpec)
+	FontHeight() float64
+	MeasureString(s string) (w, h float64)
+	WordWrap(s string, width float64) []string
+	// DrawStringAnchored draws s with its anchor point (ax, ay) at (x, y),
+	// with the same meaning as gg.Context.DrawStringAnchored.
+	DrawStringAnchored(s string, x, y, ax, ay float64)
+	StrokeRect(x, y, w, h float64)
+	FillRect(x, y, w, h float64)
+	DrawLine(x1, y1, x2, y2 float64)
+	// DrawBarcode draws a scaled barcode with its top-left corner at (x, y).
+	DrawBarcode(bc image.Image, x, y float64)
+	// DrawImage draws img, in full colour, scaled into the w x h box at (x, y).
+	DrawImage(img image.Image, x, y, w, h float64)
+}
+
+// fontSpec selects a Go Regular (or Go Bold or Go Mono) face. At 72 DPI the
+// size is in pixels.
+type fontSpec struct {
+	size, dpi  float64
+	bold, mono bool
+}
+
+// pixelSize is the em size of the font in page pixels.
+func (f fontSpec) pixelSize() float64 {
+	return f.size * f.dpi / 72
+}
+
+// font cache so we only parse the Go fonts once per size.
+var fontCache = map[fontSpec]font.Face{}
+
+// mustGoFace returns a font.Face for f, always using the embedded goregular
+// (or gobold or gomono) TTF.
+func mustGoFace(f fontSpec) font.Face {
+	if face, ok := fontCache[f]; ok {
+		return face
+	}
+
+	ttf, name := goregular.TTF, "goregular"
+	switch {
+	case f.mono:
+		ttf, name = gomono.TTF, "gomono"
+	case f.bold:
+		ttf, name = gobold.TTF, "gobold"
+	}
+	fnt, err := opentype.Parse(ttf)
+	if err != nil {
+		log.Fatalf("failed to parse %s TTF: %v", name, err)
+	}
+
+	face, err := opentype.NewFace(fnt, &opentype.FaceOptions{
+		Size:    f.size,
+		DPI:     f.dpi,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		log.Fatalf("failed to create %s face (size=%.1f): %v", name, f.size, err)
+	}
+
+	fontCache[f] = face
+	return face
+}
+
+// drawStringWrapped word-wraps s to width and draws the lines centered, the
+// first line's top at y, like gg.Context.DrawStringWrapped with AlignCenter.
+func drawStringWrapped(c canvas, s string, x, y, width, lineSpacing float64) {
+	drawLines(c, c.WordWrap(s, width), x+width/2, y, 0.5, lineSpacing)
+}
+
+// drawLines draws lines one under another, the first line's top at y and
+// each anchored horizontally at x by ax, as in DrawStringAnchored.
+func drawLines(c canvas, lines []string, x, y, ax, lineSpacing float64) {
+	for _, line := range lines {
+		c.DrawStringAnchored(line, x, y, ax, 1)
+		y += c.FontHeight() * lineSpacing
+	}
+}
+
+// measureLines returns the widest line's width and the height drawLines takes.
+func measureLines(c canvas, lines []string, lineSpacing float64) (w, h float64) {
+	for _, line := range lines {
+		lw, _ := c.MeasureString(line)
+		w = math.Max(w, lw)
+	}
+	return w, wrappedHeight(c, len(lines), lineSpacing)
+}
+
+// ellipsis ends text cut short by ellipsizeLines.
+const ellipsis = "…"
+
+// ellipsizeLines cuts each line too wide for maxWidth in the current font
+// back to what fits, ending it with an ellipsis.
+func ellipsizeLines(c canvas, lines []string, maxWidth float64) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = line
+		if w, _ := c.MeasureString(line); w <= maxWidth {
+			continue
+		}
+		r := []rune(line)
+		for out[i] = ellipsis; len(r) > 0; r = r[:len(r)-1] {
+			cut := strings.TrimRight(string(r), " ") + ellipsis
+			if w, _ := c.MeasureString(cut); w <= maxWidth {
+				out[i] = cut
+				break
+			}
+		}
+	}
+	return out
+}
+
+// wrappedHeight is the height drawStringWrapped takes for lines lines.
+func wrappedHeight(c canvas, lines int, lineSpacing float64) float64 {
+	if lines == 0 {
+		return 0
+	}
+	h := float64(lines) * c.FontHeight() * lineSpacing
+	return h - (lineSpacing-1)*c.FontHeight()
+}
+
+// rasterCanvas draws onto a gg.Context.
+type rasterCanvas struct {
+	dc *gg.Context
+}
+
+func (c *rasterCanvas) SetColor(col color.Color)  { c.dc.SetColor(col) }
+func (c *rasterCanvas) SetLineWidth(w float64)    { c.dc.SetLineWidth(w) }
+func (c *rasterCanvas) SetDash(dashes ...float64) { c.dc.SetDash(dashes...) }
+func (c *rasterCanvas) SetFont(f fontSpec)        { c.dc.SetFontFace(mustGoFace(f)) }
+func (c *rasterCanvas) FontHeight() float64       { return c.dc.FontHeight() }
+
+func (c *rasterCanvas) MeasureString(s string) (w, h float64) { return c.dc.MeasureString(s) }
+
+func (c *rasterCanvas) WordWrap(s string, width float64) []string { return c.dc.WordWrap(s, width) }
+
+func (c *rasterCanvas) DrawStringAnchored(s string, x, y, ax, ay float64) {
+	c.dc.DrawStringAnchored(s, x, y, ax, ay)
+}
+
+func (c *rasterCanvas) StrokeRect(x, y, w, h float64) {
+	c.dc.DrawRectangle(x, y, w, h)
+	c.dc.Stroke()
+}
+
+func (c *rasterCanvas) FillRect(x, y, w, h float64) {
+	c.dc.DrawRectangle(x, y, w, h)
+	c.dc.Fill()
+}
+
+func (c *rasterCanvas) DrawLine(x1, y1, x2, y2 float64) {
+	c.dc.DrawLine(x1, y1, x2, y2)
+	c.dc.Stroke()
+}
+
+// DrawBarcode rounds to the nearest pixel rather than truncating, so a
+// barcode is never shifted by almost a whole pixel.
+func (c *rasterCanvas) DrawBarcode(bc image.Image, x, y float64) {
+	c.dc.DrawImage(bc, int(math.Round(x)), int(math.Round(y)))
+}
+
+func (c *rasterCanvas) DrawImage(img image.Image, x, y, w, h float64) {
+	dst := image.NewRGBA(image.Rect(0, 0, int(math.Round(w)), int(math.Round(h))))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+	c.dc.DrawImage(dst, int(math.Round(x)), int(math.Round(y)))
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Scanner always appends a newline (<CR> / Enter).
+// All built-in Code values are complete commands and DO NOT include newline
+// characters. Command files may hold multi-line snippets; those are always QR.
+// {{remote}} is replaced with the -remote name before rendering.
+
+// GitCmd is one scannable command. Despite the name it is used for every
+// command set, not just git.
+type GitCmd struct {
+	Code        string `json:"code" yaml:"code"`                                   // exact text encoded in the barcode (no trailing newline)
+	Label       string `json:"label,omitempty" yaml:"label,omitempty"`             // short label under barcode
+	Description string `json:"description,omitempty" yaml:"description,omitempty"` // explanation under the label
+	Category    string `json:"category,omitempty" yaml:"category,omitempty"`       // group heading, e.g. "Stash"
+	// DescWidth overrides the description wrap width for this cell: up to 1
+	// it is a fraction of the cell width, above 1 a width in pixels. Zero uses
+	// the full cell width less padding.
+	DescWidth float64 `json:"desc_width,omitempty" yaml:"desc_width,omitempty"`
+	// DocURL is encoded in place of the command by QR cells under -qr-mode
+	// docs. Empty derives it from the subcommand, see GitCmd.docURL.
+	DocURL string `json:"doc_url,omitempty" yaml:"doc_url,omitempty"`
+	// Base64 means Code is standard base64 of arbitrary bytes, such as a
+	// token or config blob, that the cell's QR holds exactly, nulls and all.
+	Base64 bool `json:"base64,omitempty" yaml:"base64,omitempty"`
+	// EncodeOptions overrides Options.EncodeOptions for this command.
+	EncodeOptions map[string]string `json:"encode_options,omitempty" yaml:"encode_options,omitempty"`
+	// Disabled keeps an entry in a command file without putting it on the sheet.
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+
+	seq int // 1-based position in layout order across all pages, for -numbered; 0 is unnumbered
+}
+
+// label is the text drawn for the command: Label, or else the code itself.
+func (c GitCmd) label() string {
+	if c.Label != "" {
+		return c.Label
+	}
+	return c.Code
+}
+
+// payload decodes a Base64 command's bytes.
+func (c GitCmd) payload() ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(c.Code)
+	if err != nil {
+		return nil, fmt.Errorf("base64 payload %.40q: %w", c.Code, err)
+	}
+	return data, nil
+}
+
+// What an entry without a Label is labelled with, see Options.EmptyLabel.
+const (
+	emptyLabelCode       = "code"
+	emptyLabelNone       = "none"
+	emptyLabelSubcommand = "subcommand"
+)
+
+// label is the text drawn for c: its Label, or else what o.EmptyLabel puts in
+// its place, the code itself, nothing, or the first two words of the code,
+// e.g. "git stash" for "git stash push -m wip".
+func (o Options) label(c GitCmd) string {
+	if c.Label != "" {
+		return c.Label
+	}
+	switch o.EmptyLabel {
+	case emptyLabelNone:
+		return ""
+	case emptyLabelSubcommand:
+		first, _, _ := strings.Cut(c.Code, "\n")
+		fields := strings.Fields(first)
+		return strings.Join(fields[:min(len(fields), 2)], " ")
+	}
+	return c.label()
+}
+
+// splitLines splits text drawn on the sheet into lines, dropping the \r of a
+// \r\n line ending, which the fonts have no glyph for. The encoded payload
+// keeps it.
+func splitLines(text string) []string {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSuffix(l, "\r")
+	}
+	return lines
+}
+
+// docURL returns the documentation page for the command: DocURL, or else the
+// page of its subcommand in the built-in set named after the program, e.g.
+// "git stash pop" -> https://git-scm.com/docs/git-stash. It is empty when
+// neither is known, including for subcommands missing from the set's DocPages.
+func (c GitCmd) docURL() string {
+	if c.DocURL != "" {
+		return c.DocURL
+	}
+	fields := strings.Fields(c.Code)
+	if len(fields) < 2 {
+		return ""
+	}
+	set, err := lookupSet(fields[0])
+	if err != nil || set.DocURL == "" {
+		return ""
+	}
+	for _, f := range fields[1:] {
+		if !strings.HasPrefix(f, "-") {
+			if page, ok := set.DocPages[f]; ok {
+				return fmt.Sprintf(set.DocURL, page)
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// CommandSet is a named built-in list of commands with its own default title.
+type CommandSet struct {
+	Name     string
+	Title    string
+	DocURL   string            // documentation URL pattern, %s is the page
+	DocPages map[string]string // subcommands with a DocURL page, to the page name
+	Commands []GitCmd
+}
+
+// docPages maps each subcommand to its page name, its own name unless given
+// as "alias=page".
+func docPages(names ...string) map[string]string {
+	pages := make(map[string]string, len(names))
+	for _, n := range names {
+		sub, page, ok := strings.Cut(n, "=")
+		if !ok {
+			page = sub
+		}
+		pages[sub] = page
+	}
+	return pages
+}
+
+// The subcommands each built-in set's DocURL has a page for. Aliases point at
+// the page of the command they stand for.
+var (
+	gitDocPages = docPages(
+		"add", "am", "apply", "archive", "bisect", "blame", "branch", "bundle",
+		"checkout", "cherry-pick", "clean", "clone", "commit", "config",
+		"describe", "diff", "fetch", "format-patch", "gc", "grep", "init", "log",
+		"merge", "mv", "notes", "pull", "push", "range-diff", "rebase", "reflog",
+		"remote", "reset", "restore", "rev-parse", "revert", "rm", "shortlog",
+		"show", "sparse-checkout", "stash", "status", "submodule", "switch",
+		"tag", "worktree",
+	)
+	dockerDocPages = docPages(
+		"builder", "compose", "container", "context", "image", "inspect",
+		"login", "logout", "network", "system", "version", "volume",
+		"build=image/build", "exec=container/exec", "images=image/ls",
+		"info=system/info", "logs=container/logs", "ps=container/ls",
+		"pull=image/pull", "push=image/push", "restart=container/restart",
+		"rm=container/rm", "rmi=image/rm", "run=container/run",
+		"start=container/start", "stats=container/stats", "stop=container/stop",
+	)
+	kubectlDocPages = docPages(
+		"annotate", "api-resources", "apply", "auth", "cluster-info", "config",
+		"cp", "create", "delete", "describe", "edit", "exec", "explain",
+		"expose", "get", "label", "logs", "port-forward", "rollout", "run",
+		"scale", "top", "version",
+	)
+	npmDocPages = docPages(
+		"audit", "cache", "ci", "config", "dedupe", "doctor", "exec", "fund",
+		"init", "install", "link", "login", "ls", "outdated", "pack", "pkg",
+		"prune", "publish", "search", "start", "test", "uninstall", "update",
+		"version", "view", "whoami",
+		"i=install", "run=run-script", "run-script",
+	)
+)
+
+// defaultSet is the command set used when -set is not given.
+const defaultSet = "git"
+
+// CommandSets lists the built-in sets selectable with -set.
+var CommandSets = []CommandSet{
+	{Name: "git", Title: "Git Barcode Sheet – One Scan = One Command", DocURL: "https://git-scm.com/docs/git-%s", DocPages: gitDocPages, Commands: gitCommands},
+	{Name: "docker", Title: "Docker Barcode Sheet – One Scan = One Command", DocURL: "https://docs.docker.com/reference/cli/docker/%s/", DocPages: dockerDocPages, Commands: dockerCommands},
+	{Name: "kubectl", Title: "kubectl Barcode Sheet – One Scan = One Command", DocURL: "https://kubernetes.io/docs/reference/kubectl/generated/kubectl_%s/", DocPages: kubectlDocPages, Commands: kubectlCommands},
+	{Name: "npm", Title: "npm Barcode Sheet – One Scan = One Command", DocURL: "https://docs.npmjs.com/cli/commands/npm-%s", DocPages: npmDocPages, Commands: npmCommands},
+}
+
+// lookupSet returns the built-in set with the given name.
+func lookupSet(name string) (CommandSet, error) {
+	var names []string
+	for _, set := range CommandSets {
+		if set.Name == name {
+			return set, nil
+		}
+		names = append(names, set.Name)
+	}
+	return CommandSet{}, fmt.Errorf("unknown command set %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// section is a run of built-in commands sharing a category.
+type section struct {
+	Category string
+	Cmds     []GitCmd
+}
+
+// flattenSections concatenates sections in order, filling in each command's Category.
+func flattenSections(sections ...section) []GitCmd {
+	var cmds []GitCmd
+	for _, s := range sections {
+		for _, cmd := range s.Cmds {
+			cmd.Category = s.Category
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+// 40 git CLI commands -> 4 x 10 grid, all self-contained (no editing needed).
+var gitCommands = flattenSections(
+	section{"Status / inspection", []GitCmd{
+		{Code: "git status", Label: "git status", Description: "Show working tree status."},
+		{Code: "git status -sb", Label: "git status -sb", Description: "Short, branch-aware status."},
+		{Code: "git diff", Label: "git diff", Description: "Diff unstaged changes."},
+		{Code: "git diff --staged", Label: "git diff --staged", Description: "Diff staged changes."},
+	}},
+	section{"Staging / restoring", []GitCmd{
+		{Code: "git add .", Label: "git add .", Description: "Stage all changes in current repo."},
+		{Code: "git add -p", Label: "git add -p", Description: "Interactive patch staging."},
+		{Code: "git restore .", Label: "git restore .", Description: "Discard unstaged changes in files."},
+		{Code: "git restore --staged .", Label: "git restore --staged .", Description: "Unstage all changes."},
+	}},
+	section{"Common commit messages", []GitCmd{
+		{Code: "git commit -m \"Initial commit\"", Label: "Initial commit", Description: "Create an initial commit."},
+		{Code: "git commit -m \"Update README\"", Label: "Update README", Description: "Commit README changes."},
+		{Code: "git commit -m \"Fix bug\"", Label: "Fix bug", Description: "Commit a bugfix."},
+		{Code: "git commit -m \"Refactor code\"", Label: "Refactor code", Description: "Commit refactor changes."},
+	}},
+	section{"Generic commit / log helpers", []GitCmd{
+		{Code: "git commit -m \"WIP\"", Label: "WIP commit", Description: "Quick work-in-progress commit."},
+		{Code: "git log --oneline --graph --decorate --all", Label: "Pretty log", Description: "Compact decorated log graph."},
+		{Code: "git log --oneline", Label: "Log oneline", Description: "Short one-line commit history."},
+		{Code: "git show", Label: "git show", Description: "Show details of the latest commit."},
+	}},
+	section{"Stash", []GitCmd{
+		{Code: "git stash", Label: "git stash", Description: "Stash uncommitted changes."},
+		{Code: "git stash pop", Label: "stash pop", Description: "Apply and drop latest stash."},
+		{Code: "git stash list", Label: "stash list", Description: "List all stashes."},
+		{Code: "git stash drop", Label: "stash drop", Description: "Drop latest stash."},
+	}},
+	section{"Branching & navigation", []GitCmd{
+		{Code: "git branch", Label: "git branch", Description: "List local branches."},
+		{Code: "git branch -vv", Label: "git branch -vv", Description: "Branches with tracking info."},
+		{Code: "git checkout -", Label: "git checkout -", Description: "Switch to previous branch."},
+		{Code: "git reflog", Label: "git reflog", Description: "Show reference log for HEAD history."},
+	}},
+	section{"Sync / remotes", []GitCmd{
+		{Code: "git fetch --all --prune", Label: "fetch --all", Description: "Fetch all remotes and prune."},
+		{Code: "git pull", Label: "git pull", Description: "Pull from current upstream."},
+		{Code: "git push", Label: "git push", Description: "Push current HEAD to upstream."},
+		{Code: "git push --set-upstream {{remote}} HEAD", Label: "push -u {{remote}} HEAD", Description: "Push and set upstream."},
+	}},
+	section{"Tags / metadata", []GitCmd{
+		{Code: "git tag", Label: "git tag", Description: "List tags."},
+		{Code: "git tag -l", Label: "git tag -l", Description: "List tags (pattern-capable)."},
+		{Code: "git remote -v", Label: "git remote -v", Description: "List remotes and URLs."},
+		{Code: "git config --list", Label: "git config --list", Description: "Show all Git config entries."},
+	}},
+	section{"Search / history helpers", []GitCmd{
+		{Code: "git grep -n \"TODO\"", Label: "grep TODO", Description: "Search TODO in tracked files."},
+		{Code: "git shortlog -sn", Label: "shortlog -sn", Description: "Author summary (commits per author)."},
+		{Code: "git rev-parse --show-toplevel", Label: "repo root", Description: "Show path to repo root."},
+		{Code: "git rev-parse --abbrev-ref HEAD", Label: "current branch", Description: "Show current branch name."},
+	}},
+	section{"Cleanup / caution", []GitCmd{
+		{Code: "git status --ignored", Label: "status ignored", Description: "Status including ignored files."},
+		{Code: "git diff --stat", Label: "diff --stat", Description: "Diff summary (per-file stats)."},
+		{Code: "git clean -fd", Label: "clean -fd", Description: "Danger: remove untracked files & dirs."},
+		{Code: "git submodule update --init --recursive", Label: "submodules", Description: "Init and update submodules."},
+	}},
+)
+
+// 28 docker CLI commands -> 4 x 7 grid.
+var dockerCommands = flattenSections(
+	section{"Containers", []GitCmd{
+		{Code: "docker ps", Label: "docker ps", Description: "List running containers."},
+		{Code: "docker ps -a", Label: "docker ps -a", Description: "List all containers, including stopped."},
+		{Code: "docker stats --no-stream", Label: "stats", Description: "One-off resource usage per container."},
+		{Code: "docker container prune -f", Label: "container prune", Description: "Remove all stopped containers."},
+	}},
+	section{"Images", []GitCmd{
+		{Code: "docker images", Label: "docker images", Description: "List local images."},
+		{Code: "docker image prune -f", Label: "image prune", Description: "Remove dangling images."},
+		{Code: "docker build .", Label: "docker build .", Description: "Build the Dockerfile in the current directory."},
+		{Code: "docker build --no-cache .", Label: "build --no-cache", Description: "Rebuild without the layer cache."},
+	}},
+	section{"Compose", []GitCmd{
+		{Code: "docker compose up -d", Label: "compose up -d", Description: "Start services in the background."},
+		{Code: "docker compose down", Label: "compose down", Description: "Stop and remove services."},
+		{Code: "docker compose ps", Label: "compose ps", Description: "List services and their state."},
+		{Code: "docker compose logs -f", Label: "compose logs -f", Description: "Follow logs of all services."},
+		{Code: "docker compose pull", Label: "compose pull", Description: "Pull the latest service images."},
+		{Code: "docker compose restart", Label: "compose restart", Description: "Restart all services."},
+		{Code: "docker compose build", Label: "compose build", Description: "Build service images."},
+		{Code: "docker compose config", Label: "compose config", Description: "Show the resolved compose file."},
+	}},
+	section{"Volumes / networks", []GitCmd{
+		{Code: "docker volume ls", Label: "volume ls", Description: "List volumes."},
+		{Code: "docker volume prune -f", Label: "volume prune", Description: "Remove unused volumes."},
+		{Code: "docker network ls", Label: "network ls", Description: "List networks."},
+		{Code: "docker network prune -f", Label: "network prune", Description: "Remove unused networks."},
+	}},
+	section{"System", []GitCmd{
+		{Code: "docker version", Label: "docker version", Description: "Client and server versions."},
+		{Code: "docker info", Label: "docker info", Description: "System-wide information."},
+		{Code: "docker system df", Label: "system df", Description: "Disk usage by images, containers, volumes."},
+		{Code: "docker system prune -f", Label: "system prune", Description: "Danger: remove all unused data."},
+		{Code: "docker login", Label: "docker login", Description: "Log in to the default registry."},
+		{Code: "docker logout", Label: "docker logout", Description: "Log out of the default registry."},
+		{Code: "docker context ls", Label: "context ls", Description: "List Docker contexts."},
+		{Code: "docker builder prune -f", Label: "builder prune", Description: "Clear the build cache."},
+	}},
+)
+
+// 28 kubectl commands -> 4 x 7 grid.
+var kubectlCommands = flattenSections(
+	section{"Cluster / context", []GitCmd{
+		{Code: "kubectl config get-contexts", Label: "get-contexts", Description: "List kubeconfig contexts."},
+		{Code: "kubectl config current-context", Label: "current-context", Description: "Show the active context."},
+		{Code: "kubectl cluster-info", Label: "cluster-info", Description: "Control plane and service addresses."},
+		{Code: "kubectl version", Label: "kubectl version", Description: "Client and server versions."},
+		{Code: "kubectl api-resources", Label: "api-resources", Description: "List resource types."},
+		{Code: "kubectl get namespaces", Label: "get namespaces", Description: "List namespaces."},
+		{Code: "kubectl get nodes -o wide", Label: "get nodes", Description: "Nodes with IPs and versions."},
+		{Code: "kubectl top nodes", Label: "top nodes", Description: "Node CPU and memory usage."},
+	}},
+	section{"Workloads", []GitCmd{
+		{Code: "kubectl get pods", Label: "get pods", Description: "Pods in the current namespace."},
+		{Code: "kubectl get pods -A", Label: "get pods -A", Description: "Pods in all namespaces."},
+		{Code: "kubectl get pods -o wide", Label: "pods -o wide", Description: "Pods with node and IP."},
+		{Code: "kubectl top pods", Label: "top pods", Description: "Pod CPU and memory usage."},
+		{Code: "kubectl get deployments", Label: "get deployments", Description: "List deployments."},
+		{Code: "kubectl get replicasets", Label: "get replicasets", Description: "List replica sets."},
+		{Code: "kubectl get statefulsets", Label: "get statefulsets", Description: "List stateful sets."},
+		{Code: "kubectl get jobs", Label: "get jobs", Description: "List jobs."},
+	}},
+	section{"Networking / config", []GitCmd{
+		{Code: "kubectl get services", Label: "get services", Description: "List services."},
+		{Code: "kubectl get ingress", Label: "get ingress", Description: "List ingresses."},
+		{Code: "kubectl get endpoints", Label: "get endpoints", Description: "List service endpoints."},
+		{Code: "kubectl get configmaps", Label: "get configmaps", Description: "List config maps."},
+		{Code: "kubectl get secrets", Label: "get secrets", Description: "List secrets (names only)."},
+		{Code: "kubectl get pvc", Label: "get pvc", Description: "List persistent volume claims."},
+	}},
+	section{"Troubleshooting", []GitCmd{
+		{Code: "kubectl get events --sort-by=.lastTimestamp", Label: "recent events", Description: "Events, oldest first."},
+		{Code: "kubectl get all", Label: "get all", Description: "Common resources in the namespace."},
+		{Code: "kubectl auth can-i --list", Label: "can-i --list", Description: "What the current user may do."},
+		{Code: "kubectl get componentstatuses", Label: "componentstatuses", Description: "Control plane component health."},
+		{Code: "kubectl get --raw /readyz?verbose", Label: "readyz", Description: "API server readiness checks."},
+		{Code: "kubectl get crd", Label: "get crd", Description: "List custom resource definitions."},
+	}},
+)
+
+// 24 npm commands -> 4 x 6 grid.
+var npmCommands = flattenSections(
+	section{"Install", []GitCmd{
+		{Code: "npm install", Label: "npm install", Description: "Install dependencies from package.json."},
+		{Code: "npm ci", Label: "npm ci", Description: "Clean install from the lockfile."},
+		{Code: "npm update", Label: "npm update", Description: "Update dependencies within ranges."},
+		{Code: "npm prune", Label: "npm prune", Description: "Remove extraneous packages."},
+		{Code: "npm dedupe", Label: "npm dedupe", Description: "Flatten the dependency tree."},
+		{Code: "npm cache verify", Label: "cache verify", Description: "Check and garbage-collect the cache."},
+	}},
+	section{"Scripts", []GitCmd{
+		{Code: "npm test", Label: "npm test", Description: "Run the test script."},
+		{Code: "npm start", Label: "npm start", Description: "Run the start script."},
+		{Code: "npm run build", Label: "run build", Description: "Run the build script."},
+		{Code: "npm run dev", Label: "run dev", Description: "Run the dev script."},
+		{Code: "npm run lint", Label: "run lint", Description: "Run the lint script."},
+		{Code: "npm run", Label: "npm run", Description: "List available scripts."},
+	}},
+	section{"Inspect", []GitCmd{
+		{Code: "npm outdated", Label: "npm outdated", Description: "Show outdated dependencies."},
+		{Code: "npm ls", Label: "npm ls", Description: "Top-level installed packages."},
+		{Code: "npm ls --all", Label: "npm ls --all", Description: "Full installed dependency tree."},
+		{Code: "npm audit", Label: "npm audit", Description: "Report known vulnerabilities."},
+		{Code: "npm audit fix", Label: "audit fix", Description: "Apply compatible security fixes."},
+		{Code: "npm doctor", Label: "npm doctor", Description: "Check the npm environment."},
+	}},
+	section{"Config / publish", []GitCmd{
+		{Code: "npm config list", Label: "config list", Description: "Show effective config."},
+		{Code: "npm whoami", Label: "npm whoami", Description: "Show the logged-in user."},
+		{Code: "npm login", Label: "npm login", Description: "Log in to the registry."},
+		{Code: "npm pack --dry-run", Label: "pack --dry-run", Description: "Preview the published tarball."},
+		{Code: "npm version patch", Label: "version patch", Description: "Bump the patch version and tag."},
+		{Code: "npm publish", Label: "npm publish", Description: "Publish the package."},
+	}},
+)
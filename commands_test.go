@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestDocURL(t *testing.T) {
+	tests := []struct {
+		cmd  GitCmd
+		want string
+	}{
+		{GitCmd{Code: "git stash pop"}, "https://git-scm.com/docs/git-stash"},
+		{GitCmd{Code: "git --no-pager log --oneline"}, "https://git-scm.com/docs/git-log"},
+		{GitCmd{Code: "git frobnicate --all"}, ""},
+		{GitCmd{Code: "git -C src status"}, ""}, // "src" is not a subcommand
+		{GitCmd{Code: "docker ps -a"}, "https://docs.docker.com/reference/cli/docker/container/ls/"},
+		{GitCmd{Code: "docker compose up -d"}, "https://docs.docker.com/reference/cli/docker/compose/"},
+		{GitCmd{Code: "kubectl get pods"}, "https://kubernetes.io/docs/reference/kubectl/generated/kubectl_get/"},
+		{GitCmd{Code: "npm run build"}, "https://docs.npmjs.com/cli/commands/npm-run-script"},
+		{GitCmd{Code: "make test"}, ""},
+		{GitCmd{Code: "git"}, ""},
+		{GitCmd{Code: "git frobnicate", DocURL: "https://example.com/frob"}, "https://example.com/frob"},
+	}
+	for _, tt := range tests {
+		if got := tt.cmd.docURL(); got != tt.want {
+			t.Errorf("docURL(%q) = %q, want %q", tt.cmd.Code, got, tt.want)
+		}
+	}
+}
+
+func TestBuiltinDocPages(t *testing.T) {
+	for _, set := range CommandSets {
+		for _, cmd := range set.Commands {
+			if cmd.docURL() == "" {
+				t.Errorf("%s: %q has no docs page", set.Name, cmd.Code)
+			}
+		}
+	}
+}
+
+func TestWarnMissingDocs(t *testing.T) {
+	var logged strings.Builder
+	w := log.Writer()
+	log.SetOutput(&logged)
+	defer log.SetOutput(w)
+
+	opts := testOptions()
+	opts.Symbology = symbologyQR
+	warnMissingDocs([]GitCmd{{Code: "git status"}, {Code: "git frobnicate"}, {Code: "make test"}}, opts)
+	for _, want := range []string{"2 QR command(s)", `"git frobnicate"`, `"make test"`} {
+		if !strings.Contains(logged.String(), want) {
+			t.Errorf("warning %q doesn't contain %s", logged.String(), want)
+		}
+	}
+	if strings.Contains(logged.String(), `"git status"`) {
+		t.Errorf("warning %q lists git status, which has a page", logged.String())
+	}
+}
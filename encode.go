@@ -0,0 +1,392 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+)
+
+// defaultCode128Width is the share of the cell width a Code128 is stretched to.
+const defaultCode128Width = 0.9
+
+// code128NaturalModuleMM is the bar width Code128Natural draws at: a common
+// X-dimension, readable by virtually every scanner.
+const code128NaturalModuleMM = 0.33
+
+// barcodeSize returns the pixel box a cell's barcode with the given module
+// count (width in modules) is scaled into. Code128 gets a wide strip, QR a square.
+// With opts.MaxModulePx the box shrinks so no module is wider than the cap;
+// with Code128Natural a Code128 is only as wide as its modules at the natural
+// bar width, at most the stretched width.
+// Without descriptions, or in a list row, the barcode takes a larger share of
+// the cell height.
+func barcodeSize(cmd GitCmd, modules int, cellWidth, cellHeight float64, opts Options) (w, h int) {
+	barFrac, qrFrac := 0.45, 0.5
+	switch {
+	case opts.Layout == layoutList:
+		// A list row is just the barcode's column, nothing is stacked with it.
+		barFrac, qrFrac = 0.8, 0.85
+	case opts.NoDesc:
+		barFrac, qrFrac = 0.55, 0.6
+	}
+	if opts.isCode128(cmd) {
+		w = int(opts.code128Width(cellWidth))
+		h = int(cellHeight * barFrac)
+		if opts.Code128Natural {
+			module := int(math.Round(math.Max(mmToPx(code128NaturalModuleMM, opts.DPI), math.Ceil(mmToPx(opts.MinModuleMM, opts.DPI)))))
+			w = min(w, modules*max(module, 1))
+		}
+		if capped := modules * opts.MaxModulePx; opts.MaxModulePx > 0 && capped < w {
+			// Keep the strip's proportions rather than leaving full-height bars.
+			h = h * capped / w
+			w = capped
+		}
+		if b := opts.balance; b.barModule > 0 && modules*b.barModule < w {
+			w = modules * b.barModule
+		}
+		if opts.balance.barHeight > h {
+			h = opts.balance.barHeight
+		}
+		if opts.BarHeightMM > 0 {
+			h = int(mmToPx(opts.BarHeightMM, opts.DPI))
+		}
+		return w, h
+	}
+	qrSize := int(math.Min(cellWidth*0.75, cellHeight*qrFrac))
+	if capped := modules * opts.MaxModulePx; opts.MaxModulePx > 0 && capped < qrSize {
+		qrSize = capped
+	}
+	if b := opts.balance; b.qrModule > 0 && modules*b.qrModule < qrSize {
+		qrSize = modules * b.qrModule
+	}
+	return qrSize, qrSize
+}
+
+// balancedSizes are the shared barcode sizes worked out by balanceSizes.
+// Zero fields leave barcodeSize's per-cell sizing alone.
+type balancedSizes struct {
+	barModule int // Code128 module width in pixels
+	qrModule  int // QR module width in pixels
+	barHeight int // Code128 bar height, matched to the tallest QR
+}
+
+// balanceSizes gives every Code128 and every QR on the sheet the same module
+// width so symbols carry comparable visual weight: the smallest any cell of
+// the given size allows, but never below opts.MinModuleMM. Code128 bars then
+// grow to the height of the QR codes, up to the 0.6 cell height limit.
+func balanceSizes(cmds []GitCmd, cellWidth, cellHeight float64, opts Options) balancedSizes {
+	opts.balance = balancedSizes{}
+	var b balancedSizes
+	var qrModules []int
+	for _, cmd := range cmds {
+		raw, err := encodeRaw(cmd, opts)
+		if err != nil {
+			continue
+		}
+		modules := raw.Bounds().Dx()
+		w, _ := barcodeSize(cmd, modules, cellWidth, cellHeight, opts)
+		module := w / modules
+		if opts.isCode128(cmd) {
+			if b.barModule == 0 || module < b.barModule {
+				b.barModule = module
+			}
+		} else {
+			if b.qrModule == 0 || module < b.qrModule {
+				b.qrModule = module
+			}
+			qrModules = append(qrModules, modules)
+		}
+	}
+
+	// Balancing may shrink a barcode below the size it would get on its own,
+	// but not below what the scanner needs.
+	minModule := int(math.Ceil(mmToPx(opts.MinModuleMM, opts.DPI)))
+	b.barModule = max(b.barModule, minModule)
+	if b.qrModule > 0 {
+		b.qrModule = max(b.qrModule, minModule)
+	}
+	for _, modules := range qrModules {
+		b.barHeight = max(b.barHeight, modules*b.qrModule)
+	}
+	b.barHeight = min(b.barHeight, int(cellHeight*0.6))
+	return b
+}
+
+// isShort reports whether code is drawn as Code128 by default: a single line
+// of at most shortCmdMaxLen characters. Everything else is QR.
+func isShort(code string) bool {
+	return len(code) <= shortCmdMaxLen && !strings.Contains(code, "\n")
+}
+
+// Symbologies selectable with -all-qr and -all-code128, see Options.Symbology.
+const (
+	symbologyAuto    = ""
+	symbologyQR      = "qr"
+	symbologyCode128 = "code128"
+)
+
+// isCode128 reports whether cmd is drawn as Code128 rather than QR: as
+// isShort decides, unless o.Symbology forces one for every command. Base64
+// payloads are always QR.
+func (o Options) isCode128(cmd GitCmd) bool {
+	if cmd.Base64 {
+		return false
+	}
+	switch o.Symbology {
+	case symbologyQR:
+		return false
+	case symbologyCode128:
+		return true
+	}
+	return isShort(cmd.Code)
+}
+
+// phoneQRMaxModules is the width of a version 10 QR. Phone cameras struggle
+// with denser codes at cell size, so -all-qr warns about them.
+const phoneQRMaxModules = 57
+
+// warnDenseQRs logs the commands whose QR is wider than phoneQRMaxModules.
+func warnDenseQRs(cmds []GitCmd, opts Options) {
+	for _, cmd := range cmds {
+		if raw, err := encodeRaw(cmd, opts); err == nil && raw.Bounds().Dx() > phoneQRMaxModules {
+			log.Printf("warning: %q needs a %d-module QR, hard for a phone camera to read at cell size; shorten it or use fewer -cols", cmd.Code, raw.Bounds().Dx())
+		}
+	}
+}
+
+// What QR cells encode, see Options.QRMode. qrModeSCMDocs is another name
+// for qrModeDocs, after git-scm.com where the git pages live.
+const (
+	qrModeCommand = "command"
+	qrModeDocs    = "docs"
+	qrModeSCMDocs = "scm-docs"
+)
+
+// warnMissingDocs logs the QR commands that have no documentation URL under
+// qrModeDocs, whose QR falls back to the command itself.
+func warnMissingDocs(cmds []GitCmd, opts Options) {
+	var missing []string
+	for _, cmd := range cmds {
+		if !opts.isCode128(cmd) && !cmd.Base64 && cmd.docURL() == "" {
+			missing = append(missing, fmt.Sprintf("%q", cmd.Code))
+		}
+	}
+	if len(missing) > 0 {
+		log.Printf("warning: no docs page for %d QR command(s), which encode the command instead (set doc_url to give one): %s", len(missing), strings.Join(missing, ", "))
+	}
+}
+
+// qrEncodings are the -qr-encoding modes. Auto picks the densest mode that
+// can hold the content; forcing a mode fails on content outside its charset.
+var qrEncodings = map[string]qr.Encoding{
+	"auto":         qr.Auto,
+	"byte":         qr.Unicode,
+	"alphanumeric": qr.AlphaNumeric,
+	"numeric":      qr.Numeric,
+}
+
+// qrEncodingCharsets describes what the restricted modes can hold, for errors.
+var qrEncodingCharsets = map[string]string{
+	"alphanumeric": "0-9, A-Z, space and $%*+-./:",
+	"numeric":      "0-9",
+}
+
+// checkQREncoding fails, naming each, if any QR cell of cmds can't be encoded
+// in the mode forced by opts.QREncoding or its qr.encoding option.
+func checkQREncoding(cmds []GitCmd, opts Options) error {
+	var errs []error
+	modes := map[string]bool{}
+	for _, cmd := range cmds {
+		_, mode := opts.qrSettings(cmd)
+		if opts.isCode128(cmd) || mode == "auto" {
+			continue
+		}
+		if _, err := encodeRaw(cmd, opts); err != nil {
+			errs = append(errs, err)
+			modes[mode] = true
+		}
+	}
+	for _, mode := range sortedKeys(modes) {
+		if qrEncodingCharsets[mode] != "" {
+			errs = append(errs, fmt.Errorf("%s mode holds only %s", mode, qrEncodingCharsets[mode]))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// encodeRaw encodes cmd unscaled: Code128 for short commands, QR for long ones,
+// unless opts.Symbology forces one.
+// Under qrModeDocs the QR holds the command's documentation URL when it has one.
+// A Base64 command's QR holds its decoded bytes, in byte mode.
+// The command's encode options, see encodeOptionKeys, tune either encoder.
+func encodeRaw(cmd GitCmd, opts Options) (barcode.Barcode, error) {
+	code := cmd.Code
+	if cmd.Base64 {
+		data, err := cmd.payload()
+		if err != nil {
+			return nil, err
+		}
+		raw, err := encodeQRCell(string(data), cmd, opts, "byte")
+		if err != nil {
+			return nil, fmt.Errorf("QR encode %d byte payload: %w", len(data), err)
+		}
+		return raw, nil
+	}
+	if opts.isCode128(cmd) {
+		raw, err := encodeCode128(code, cmd, opts)
+		if err != nil {
+			return nil, fmt.Errorf("Code128 encode %q: %w", code, err)
+		}
+		return raw, nil
+	}
+	if url := cmd.docURL(); opts.QRMode == qrModeDocs && url != "" {
+		code = url
+	}
+	raw, err := encodeQRCell(code, cmd, opts, "")
+	if err != nil {
+		return nil, fmt.Errorf("QR encode %q: %w", code, err)
+	}
+	return raw, nil
+}
+
+// What happens to a command too long for a QR code, see Options.OverflowCommand.
+const (
+	overflowCommandSkip     = "skip"
+	overflowCommandTruncate = "truncate"
+	overflowCommandError    = "error"
+)
+
+// truncatedMarker starts the label of a command cut short under
+// overflowCommandTruncate, so the sheet shows it won't run as written.
+const truncatedMarker = "(truncated)"
+
+// fitCommands applies opts.OverflowCommand to the QR commands that are too
+// long to encode: it drops them, cuts them to the longest prefix that
+// encodes, or returns an error naming them.
+func fitCommands(cmds []GitCmd, opts Options) ([]GitCmd, error) {
+	var out []GitCmd
+	var over []string
+	for _, cmd := range cmds {
+		if opts.isCode128(cmd) {
+			out = append(out, cmd)
+			continue
+		}
+		if _, err := encodeRaw(cmd, opts); err == nil {
+			out = append(out, cmd)
+			continue
+		}
+		over = append(over, fmt.Sprintf("%.40q (%d bytes)", cmd.Code, len(cmd.Code)))
+		switch opts.OverflowCommand {
+		case overflowCommandTruncate:
+			if t, ok := truncateCommand(cmd, opts); ok {
+				log.Printf("warning: %.40q is too long for a QR code; truncated to %d of %d bytes", cmd.Code, len(t.Code), len(cmd.Code))
+				out = append(out, t)
+				continue
+			}
+			log.Printf("warning: %.40q can't be encoded even truncated; skipped", cmd.Code)
+		case overflowCommandSkip:
+			log.Printf("warning: %.40q is too long for a QR code (%d bytes); skipped", cmd.Code, len(cmd.Code))
+		}
+	}
+	if opts.OverflowCommand == overflowCommandError && len(over) > 0 {
+		return nil, fmt.Errorf("too long for a QR code:\n  %s", strings.Join(over, "\n  "))
+	}
+	return out, nil
+}
+
+// truncateCommand cuts cmd to the longest prefix, in whole runes, that still
+// encodes, and marks its label. It reports false if no prefix does.
+func truncateCommand(cmd GitCmd, opts Options) (GitCmd, bool) {
+	if cmd.Base64 {
+		return cmd, false // a cut payload is just broken data
+	}
+	runes := []rune(cmd.Code)
+	cut := func(n int) GitCmd {
+		c := cmd
+		c.Code = string(runes[:n])
+		return c
+	}
+	// Encoding succeeds up to some length and fails beyond it.
+	n := sort.Search(len(runes)+1, func(n int) bool {
+		_, err := encodeRaw(cut(n), opts)
+		return err != nil
+	}) - 1
+	if n <= 0 {
+		return cmd, false
+	}
+	t := cut(n)
+	t.Label = strings.TrimSpace(truncatedMarker + " " + opts.label(t))
+	return t, true
+}
+
+// encodeCell encodes cmd and scales it to fit a cell of the given size.
+func encodeCell(cmd GitCmd, cellWidth, cellHeight float64, opts Options) (barcode.Barcode, error) {
+	raw, err := encodeRaw(cmd, opts)
+	if err != nil {
+		return nil, err
+	}
+	w, h := barcodeSize(cmd, raw.Bounds().Dx(), cellWidth, cellHeight, opts)
+	return barcode.Scale(raw, w, h)
+}
+
+// belowMinModule lists the codes whose modules would be narrower than
+// opts.MinModuleMM (or whose fixed bar height won't fit) with the given column count.
+func belowMinModule(cmds []GitCmd, cols int, gridWidth, gridHeight float64, opts Options) []string {
+	rows := int(math.Ceil(float64(len(cmds)) / float64(cols)))
+	opts, cellWidth := opts.cellStack(gridWidth / float64(cols))
+	cellHeight := gridHeight / float64(rows)
+	minModule := mmToPx(opts.MinModuleMM, opts.DPI)
+
+	var bad []string
+	for _, cmd := range cmds {
+		raw, err := encodeRaw(cmd, opts)
+		if err != nil {
+			bad = append(bad, cmd.Code)
+			continue
+		}
+		w, h := barcodeSize(cmd, raw.Bounds().Dx(), cellWidth, cellHeight, opts)
+		module := w / raw.Bounds().Dx()
+		if float64(module) < math.Max(minModule, 1) || float64(h) > cellHeight*0.6 {
+			bad = append(bad, cmd.Code)
+		}
+	}
+	return bad
+}
+
+// autoCols picks the largest column count (up to maxAutoCols) at which every
+// barcode still meets the physical size constraints. If no count satisfies them
+// all, the one with the fewest violations wins and a warning is logged.
+func autoCols(cmds []GitCmd, gridWidth, gridHeight float64, opts Options) int {
+	best, bestBad := 1, -1
+	for cols := maxAutoCols; cols >= 1; cols-- {
+		bad := belowMinModule(cmds, cols, gridWidth, gridHeight, opts)
+		if len(bad) == 0 {
+			return cols
+		}
+		if bestBad < 0 || len(bad) < bestBad {
+			best, bestBad = cols, len(bad)
+		}
+	}
+	log.Printf("warning: no column count satisfies the physical size constraints; %d command(s) still fall short", bestBad)
+	return best
+}
+
+// encodeQR encodes content as a QR code with the given error correction level,
+// scaled to size x size pixels.
+func encodeQR(content string, size int, level qr.ErrorCorrectionLevel) (barcode.Barcode, error) {
+	raw, err := qr.Encode(content, level, qr.Auto)
+	if err != nil {
+		return nil, fmt.Errorf("QR encode %q: %w", content, err)
+	}
+	scaled, err := barcode.Scale(raw, size, size)
+	if err != nil {
+		return nil, fmt.Errorf("QR scale %q: %w", content, err)
+	}
+	return scaled, nil
+}
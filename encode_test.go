@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing"
+)
+
+func TestFitCommands(t *testing.T) {
+	quietLog(t)
+	long := GitCmd{Code: strings.Repeat("git commit --allow-empty -m wip && ", 200)}
+	cmds := []GitCmd{{Code: "git status"}, long}
+	tests := []struct {
+		policy  string
+		wantLen int
+		wantErr bool
+	}{
+		{overflowCommandSkip, 1, false},
+		{overflowCommandTruncate, 2, false},
+		{overflowCommandError, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			opts := testOptions()
+			opts.OverflowCommand = tt.policy
+			got, err := fitCommands(cmds, opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("accepted a command too long for any QR code")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.wantLen {
+				t.Fatalf("kept %d of 2 commands, want %d", len(got), tt.wantLen)
+			}
+			if tt.policy != overflowCommandTruncate {
+				return
+			}
+			cut := got[1]
+			if _, err := encodeRaw(cut, opts); err != nil {
+				t.Errorf("truncated command doesn't encode: %v", err)
+			}
+			if !strings.HasPrefix(long.Code, cut.Code) || !strings.HasPrefix(cut.Label, truncatedMarker) {
+				t.Errorf("truncated to %.40q labelled %.40q, want a prefix labelled %q", cut.Code, cut.Label, truncatedMarker)
+			}
+		})
+	}
+}
+
+func TestBase64QR(t *testing.T) {
+	quietLog(t)
+	want := []byte{0, 'g', 'i', 't', 0, 0xff, 0x80, '\n', 0}
+	cmd := GitCmd{Code: base64.StdEncoding.EncodeToString(want), Label: "payload", Base64: true}
+	opts := testOptions()
+	opts.Cols = 2
+	dc, report, err := renderSheet([]GitCmd{{Code: "git status"}, cmd}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := decodeQR(dc.Image(), report.Cells[1].Elements[cellBarcode])
+	if err != nil {
+		t.Fatalf("base64 payload QR does not decode: %v", err)
+	}
+	var got []byte
+	segments, _ := res.GetResultMetadata()[gozxing.ResultMetadataType_BYTE_SEGMENTS].([][]byte)
+	for _, s := range segments {
+		got = append(got, s...)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("base64 payload QR decodes to % x, want % x", got, want)
+	}
+}
+
+func TestMultiLineQRRoundTrip(t *testing.T) {
+	quietLog(t)
+	tests := []struct {
+		name string
+		file string
+		body string
+		want string
+	}{
+		{"yaml", "cmds.yaml", "- code: |\n    git fetch\n    git status -sb\n", "git fetch\ngit status -sb"},
+		// YAML folds every line break to \n, so a file saved on Windows scans the same.
+		{"yaml crlf", "cmds.yaml", "- code: |\r\n    git fetch\r\n    git status -sb\r\n", "git fetch\ngit status -sb"},
+		{"json lf", "cmds.json", `[{"code": "git fetch\ngit status -sb"}]`, "git fetch\ngit status -sb"},
+		{"json crlf", "cmds.json", `[{"code": "git fetch\r\ngit status -sb"}]`, "git fetch\r\ngit status -sb"},
+		{"blank line", "cmds.json", `[{"code": "git fetch\n\ngit status -sb"}]`, "git fetch\n\ngit status -sb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.body), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cmds, err := loadCommands(path, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cmds[0].Code != tt.want {
+				t.Fatalf("loaded %q, want %q", cmds[0].Code, tt.want)
+			}
+			opts := testOptions()
+			opts.Cols = 2
+			dc, report, err := renderSheet(cmds, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isShort(cmds[0].Code) {
+				t.Fatalf("%q drawn as Code128", cmds[0].Code)
+			}
+			res, err := decodeQR(dc.Image(), report.Cells[0].Elements[cellBarcode])
+			if err != nil {
+				t.Fatalf("QR does not decode: %v", err)
+			}
+			if res.GetText() != tt.want {
+				t.Errorf("QR decodes to %q, want %q", res.GetText(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMultiLineLabelCRLF(t *testing.T) {
+	quietLog(t)
+	if got := splitLines("git fetch\r\ngit status -sb\r\n"); strings.Join(got, "|") != "git fetch|git status -sb|" {
+		t.Errorf("splitLines kept the \\r: %q", got)
+	}
+	opts := testOptions()
+	opts.Cols = 2
+	for _, layout := range []string{layoutGrid, layoutList} {
+		t.Run(layout, func(t *testing.T) {
+			opts.Layout = layout
+			// The same code under both labels, so only the label can differ.
+			render := func(label string) []byte {
+				t.Helper()
+				dc, _, err := renderSheet([]GitCmd{{Code: "git fetch\ngit status -sb", Label: label}}, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return dc.Image().(*image.RGBA).Pix
+			}
+			if !bytes.Equal(render("git fetch\ngit status -sb"), render("git fetch\r\ngit status -sb")) {
+				t.Error("a CRLF label draws differently from the same label with LF")
+			}
+		})
+	}
+}
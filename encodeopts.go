@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+)
+
+// encodeOptionKeys are the -encode-opts and encode_options keys, each with a
+// check of its value. A key is named symbology.setting and only affects
+// cells drawn in that symbology.
+//
+//	code128.checksum  true (default) or false to leave off the check symbol
+//	code128.gs1       true to start with FNC1, making the symbol GS1-128
+//	qr.ec             L, M, Q or H, in place of -qr-ec
+//	qr.encoding       a -qr-encoding mode, in place of -qr-encoding
+//
+// boombuler/barcode picks the QR mask itself, so there is no key for it.
+var encodeOptionKeys = map[string]func(string) error{
+	"code128.checksum": checkBoolOption,
+	"code128.gs1":      checkBoolOption,
+	"qr.ec": func(v string) error {
+		if _, ok := qrLevels[v]; !ok {
+			return errors.New("want L, M, Q or H")
+		}
+		return nil
+	},
+	"qr.encoding": func(v string) error {
+		if _, ok := qrEncodings[v]; !ok {
+			return errors.New("want auto, byte, alphanumeric or numeric")
+		}
+		return nil
+	},
+}
+
+// checkBoolOption accepts what strconv.ParseBool does.
+func checkBoolOption(v string) error {
+	_, err := strconv.ParseBool(v)
+	if err != nil {
+		return errors.New("want true or false")
+	}
+	return nil
+}
+
+// parseEncodeOptions parses -encode-opts, comma-separated key=value pairs,
+// and checks them with validateEncodeOptions.
+func parseEncodeOptions(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	m := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not key=value", pair)
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m, validateEncodeOptions(m)
+}
+
+// validateEncodeOptions fails, naming each, on keys not in encodeOptionKeys
+// and on values their key doesn't accept.
+func validateEncodeOptions(m map[string]string) error {
+	var errs []error
+	for _, k := range sortedKeys(m) {
+		check, ok := encodeOptionKeys[k]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown key %q: want one of %s", k, strings.Join(sortedKeys(encodeOptionKeys), ", ")))
+			continue
+		}
+		if err := check(m[k]); err != nil {
+			errs = append(errs, fmt.Errorf("%s=%q: %w", k, m[k], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// encodeOption returns cmd's value for key, from its own EncodeOptions or
+// else opts.EncodeOptions, and whether either set it.
+func (o Options) encodeOption(cmd GitCmd, key string) (string, bool) {
+	if v, ok := cmd.EncodeOptions[key]; ok {
+		return v, true
+	}
+	v, ok := o.EncodeOptions[key]
+	return v, ok
+}
+
+// encodeOptionBool is encodeOption for a boolean key, def when unset.
+// Values were checked when loaded, so a bad one reads as def.
+func (o Options) encodeOptionBool(cmd GitCmd, key string, def bool) bool {
+	v, ok := o.encodeOption(cmd, key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// qrSettings are the error correction level and encoding mode cmd's QR is
+// encoded with: qr.ec and qr.encoding, else -qr-ec and -qr-encoding.
+func (o Options) qrSettings(cmd GitCmd) (qr.ErrorCorrectionLevel, string) {
+	level, encoding := o.QRLevel, o.QREncoding
+	if v, ok := o.encodeOption(cmd, "qr.ec"); ok {
+		level = qrLevels[v]
+	}
+	if v, ok := o.encodeOption(cmd, "qr.encoding"); ok {
+		encoding = v
+	}
+	return level, encoding
+}
+
+// encodeCode128 encodes content as Code128 with cmd's code128.* options.
+func encodeCode128(content string, cmd GitCmd, opts Options) (barcode.Barcode, error) {
+	if opts.encodeOptionBool(cmd, "code128.gs1", false) {
+		content = string(code128.FNC1) + content
+	}
+	if !opts.encodeOptionBool(cmd, "code128.checksum", true) {
+		return code128.EncodeWithoutChecksum(content)
+	}
+	return code128.Encode(content)
+}
+
+// encodeQRCell encodes content as a QR with cmd's qr.* options, forcing
+// encoding to mode when it isn't empty.
+func encodeQRCell(content string, cmd GitCmd, opts Options, mode string) (barcode.Barcode, error) {
+	level, encoding := opts.qrSettings(cmd)
+	if mode != "" {
+		encoding = mode
+	}
+	return qr.Encode(content, level, qrEncodings[encoding])
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+func TestParseEncodeOptions(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]string
+		wantErr string
+	}{
+		{in: "", want: nil},
+		{in: "code128.checksum=false", want: map[string]string{"code128.checksum": "false"}},
+		{in: " qr.ec = H , qr.encoding=byte", want: map[string]string{"qr.ec": "H", "qr.encoding": "byte"}},
+		{in: "code128.gs1=1", want: map[string]string{"code128.gs1": "1"}},
+		{in: "qr.mask=1", wantErr: `unknown key "qr.mask"`},
+		{in: "code128.checksum=maybe", wantErr: "want true or false"},
+		{in: "qr.ec=Z", wantErr: "want L, M, Q or H"},
+		{in: "qr.encoding=kanji", wantErr: "want auto, byte, alphanumeric or numeric"},
+		{in: "qr.ec", wantErr: "not key=value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseEncodeOptions(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseEncodeOptions(%q) error = %v, want one containing %q", tt.in, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseEncodeOptions(%q): %v", tt.in, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseEncodeOptions(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("parseEncodeOptions(%q)[%q] = %q, want %q", tt.in, k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateEncodeOptionsNamesEachProblem(t *testing.T) {
+	err := validateEncodeOptions(map[string]string{"qr.mask": "1", "qr.ec": "Z", "code128.gs1": "true"})
+	if err == nil {
+		t.Fatal("validateEncodeOptions accepted an unknown key and a bad value")
+	}
+	for _, want := range []string{`"qr.mask"`, `qr.ec="Z"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't name %s", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), `code128.gs1="true"`) {
+		t.Errorf("error %q names the valid code128.gs1", err)
+	}
+}
+
+func TestEncodeOptionsApply(t *testing.T) {
+	// A clean baseline, so nothing but the options under test changes the symbol.
+	base := Options{QRMode: qrModeCommand, QREncoding: "auto", QRLevel: qr.L}
+	bars := GitCmd{Code: "git status"}
+	square := GitCmd{Code: strings.Repeat("git log --oneline ", 4)}
+	width := func(t *testing.T, cmd GitCmd, opts Options) int {
+		t.Helper()
+		raw, err := encodeRaw(cmd, opts)
+		if err != nil {
+			t.Fatalf("encodeRaw(%q): %v", cmd.Code, err)
+		}
+		return raw.Bounds().Dx()
+	}
+	with := func(cmd GitCmd, m map[string]string) GitCmd {
+		cmd.EncodeOptions = m
+		return cmd
+	}
+	sheet := func(m map[string]string) Options {
+		o := base
+		o.EncodeOptions = m
+		return o
+	}
+	levelH, err := qr.Encode(square.Code, qr.H, qr.Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := width(t, bars, base)
+	small := width(t, square, base)
+
+	// A Code128 symbol character is 11 modules wide.
+	tests := []struct {
+		name string
+		cmd  GitCmd
+		opts Options
+		want int
+	}{
+		{"no options", bars, base, plain},
+		{"sheet checksum=false", bars, sheet(map[string]string{"code128.checksum": "false"}), plain - 11},
+		{"sheet gs1=true", bars, sheet(map[string]string{"code128.gs1": "true"}), plain + 11},
+		{"command checksum=false", with(bars, map[string]string{"code128.checksum": "false"}), base, plain - 11},
+		{"command overrides sheet", with(bars, map[string]string{"code128.checksum": "true"}), sheet(map[string]string{"code128.checksum": "false"}), plain},
+		{"qr keys leave Code128 alone", bars, sheet(map[string]string{"qr.ec": "H"}), plain},
+		{"sheet qr.ec=H", square, sheet(map[string]string{"qr.ec": "H"}), levelH.Bounds().Dx()},
+		{"command qr.ec=H over sheet L", with(square, map[string]string{"qr.ec": "H"}), sheet(map[string]string{"qr.ec": "L"}), levelH.Bounds().Dx()},
+		{"code128 keys leave QR alone", square, sheet(map[string]string{"code128.gs1": "true"}), small},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := width(t, tt.cmd, tt.opts); got != tt.want {
+				t.Errorf("symbol is %d modules wide, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if small >= levelH.Bounds().Dx() {
+		t.Errorf("level L QR (%d modules) isn't smaller than level H (%d)", small, levelH.Bounds().Dx())
+	}
+	if _, err := encodeRaw(with(square, map[string]string{"qr.encoding": "numeric"}), base); err == nil {
+		t.Error("qr.encoding=numeric encoded a command with letters in it")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"image"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/boombuler/barcode"
+	"github.com/fogleman/gg"
+)
+
+// Formats -export-format writes.
+const (
+	exportPNG = "png"
+	exportSVG = "svg"
+)
+
+// exportModuleMM is the module width of exported barcodes, and
+// exportBarHeightMM the Code128 bar height without -bar-height-mm.
+const (
+	exportModuleMM    = 0.4
+	exportBarHeightMM = 12
+)
+
+// exportSlugMax caps the length of an export file name, before the suffix.
+const exportSlugMax = 60
+
+// exportArt is the layout of one exported barcode, in pixels at opts.DPI:
+// the label lines centred above the barcode, inside a quiet zone.
+type exportArt struct {
+	raw            barcode.Barcode // unscaled, one pixel per module
+	module, barH   float64         // module width and barcode height
+	quiet          float64         // margin on every side
+	lines          []string
+	font           fontSpec
+	lineH, labelH  float64 // one label line, all of them
+	width, height  float64
+	barX, barY     float64 // top-left of the barcode
+	labelX, labelY float64 // top centre of the label
+}
+
+// layoutExport lays out cmd's barcode and label for export.
+func layoutExport(cmd GitCmd, opts Options) (exportArt, error) {
+	raw, err := encodeRaw(cmd, opts)
+	if err != nil {
+		return exportArt{}, err
+	}
+	module := math.Max(math.Round(mmToPx(max(exportModuleMM, opts.MinModuleMM), opts.DPI)), 1)
+	a := exportArt{raw: raw, module: module}
+	modules := raw.Bounds().Dx()
+	a.barH = float64(raw.Bounds().Dy()) * module
+	a.quiet = 4 * module
+	if opts.isCode128(cmd) {
+		a.barH = math.Round(mmToPx(exportBarHeightMM, opts.DPI))
+		if opts.BarHeightMM > 0 {
+			a.barH = math.Round(mmToPx(opts.BarHeightMM, opts.DPI))
+		}
+		a.quiet = 10 * module
+	}
+
+	barW := float64(modules) * module
+	textW, gap := 0.0, 0.0
+	if label := opts.label(cmd); label != "" && !opts.NoText {
+		mc := &rasterCanvas{gg.NewContext(1, 1)}
+		a.font = opts.font(opts.fontSize(opts.LabelSize, 24))
+		mc.SetFont(a.font)
+		a.lines = splitLines(label)
+		textW, a.labelH = measureLines(mc, a.lines, labelSpacing)
+		a.lineH = mc.FontHeight()
+		gap = opts.px(cellGap)
+	}
+	a.width = max(barW, textW) + 2*a.quiet
+	a.height = a.quiet + a.labelH + gap + a.barH + a.quiet
+	a.labelX, a.labelY = a.width/2, a.quiet
+	a.barX, a.barY = (a.width-barW)/2, a.quiet+a.labelH+gap
+	return a, nil
+}
+
+// exportSlug turns code into a file name: lower-case letters and digits with
+// single dashes between words, e.g. "git status -sb" -> "git-status-sb".
+func exportSlug(code string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(code) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			dash = true
+			continue
+		}
+		if b.Len()+utf8.RuneLen(r)+1 > exportSlugMax {
+			break
+		}
+		if dash && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		dash = false
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "command"
+	}
+	return b.String()
+}
+
+// exportCommands writes each of cmds to dir as its own format file, named by
+// exportSlug with -2, -3, ... added when two codes slug alike, and returns
+// the paths written.
+func exportCommands(dir, format string, cmds []GitCmd, opts Options) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	var paths []string
+	for _, cmd := range cmds {
+		art, err := layoutExport(cmd, opts)
+		if err != nil {
+			return paths, err
+		}
+		name := exportSlug(cmd.Code)
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%d", exportSlug(cmd.Code), n)
+		}
+		used[name] = true
+
+		path := filepath.Join(dir, name+"."+format)
+		err = writeFileAtomic(path, func(w io.Writer) error {
+			if format == exportSVG {
+				return writeExportSVG(w, art, opts)
+			}
+			return encodePNG(w, renderExport(art), opts.DPI, opts.PNGCompression)
+		})
+		if err != nil {
+			return paths, fmt.Errorf("%s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// renderExport draws art as a raster image.
+func renderExport(a exportArt) image.Image {
+	dc := gg.NewContext(int(a.width+0.5), int(a.height+0.5))
+	dc.SetRGB(1, 1, 1)
+	dc.Clear()
+	c := &rasterCanvas{dc}
+	c.SetColor(image.Black.C)
+	for _, r := range exportBars(a) {
+		c.FillRect(r.X, r.Y, r.W, r.H)
+	}
+	if len(a.lines) > 0 {
+		c.SetFont(a.font)
+		drawLines(c, a.lines, a.labelX, a.labelY, 0.5, labelSpacing)
+	}
+	return dc.Image()
+}
+
+// exportBars returns art's dark modules as rectangles, each run of
+// neighbouring dark modules in a row merged into one.
+func exportBars(a exportArt) []rect {
+	b := a.raw.Bounds()
+	rowH := a.barH / float64(b.Dy())
+	var out []rect
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); {
+			if !isDark(a.raw.At(b.Min.X+x, b.Min.Y+y)) {
+				x++
+				continue
+			}
+			start := x
+			for x < b.Dx() && isDark(a.raw.At(b.Min.X+x, b.Min.Y+y)) {
+				x++
+			}
+			out = append(out, rect{a.barX + float64(start)*a.module, a.barY + float64(y)*rowH, float64(x-start) * a.module, rowH})
+		}
+	}
+	return out
+}
+
+// writeExportSVG writes art as an SVG sized in millimetres for opts.DPI: the
+// barcode as rects, the label as text in the Go font, falling back to
+// sans-serif where it isn't installed.
+func writeExportSVG(w io.Writer, a exportArt, opts Options) error {
+	bw := bufio.NewWriter(w)
+	mm := func(px float64) float64 { return px / opts.DPI * 25.4 }
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.2fmm\" height=\"%.2fmm\" viewBox=\"0 0 %g %g\">\n", mm(a.width), mm(a.height), a.width, a.height)
+	fmt.Fprintf(bw, "<rect width=\"%g\" height=\"%g\" fill=\"#fff\"/>\n", a.width, a.height)
+	fmt.Fprintln(bw, "<g fill=\"#000\" shape-rendering=\"crispEdges\">")
+	for _, r := range exportBars(a) {
+		fmt.Fprintf(bw, "<rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\"/>\n", r.X, r.Y, r.W, r.H)
+	}
+	fmt.Fprintln(bw, "</g>")
+	if len(a.lines) > 0 {
+		fmt.Fprintf(bw, "<g font-family=\"Go, sans-serif\" font-size=\"%g\" text-anchor=\"middle\">\n", a.font.pixelSize())
+		for i, line := range a.lines {
+			// drawLines puts each line's top at y, so its baseline is a line height below.
+			y := a.labelY + float64(i)*a.lineH*labelSpacing + a.lineH
+			fmt.Fprintf(bw, "<text x=\"%g\" y=\"%g\" xml:space=\"preserve\">%s</text>\n", a.labelX, y, html.EscapeString(line))
+		}
+		fmt.Fprintln(bw, "</g>")
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
@@ -8,7 +8,14 @@ require (
 	golang.org/x/image v0.21.0 // or latest
 )
 
+require (
+	github.com/go-pdf/fpdf v0.9.0
+	github.com/makiuchi-d/gozxing v0.1.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	golang.org/x/text v0.19.0 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
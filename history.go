@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// historyAuto makes -from-history look for the usual shell history files.
+const historyAuto = "auto"
+
+// defaultHistoryFiles are tried in order for -from-history auto, relative to
+// the home directory.
+var defaultHistoryFiles = []string{".zsh_history", ".bash_history"}
+
+// historyOptions controls how a shell history is turned into commands.
+type historyOptions struct {
+	Program string // only lines running this program are kept, e.g. "git"
+	Top     int    // keep the Top most frequent commands; 0 keeps all
+	Strip   bool   // drop arguments that may hold secrets
+}
+
+// loadHistory builds a command list from the shell history at path (or the
+// first default history file found, for historyAuto), ordered by how often
+// each command was run.
+func loadHistory(path string, hopts historyOptions) ([]GitCmd, error) {
+	if path == historyAuto {
+		found, err := findHistoryFile()
+		if err != nil {
+			return nil, err
+		}
+		path = found
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counts := map[string]int{}
+	var order []string // first-seen order, for stable ties
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := historyCommand(sc.Text())
+		if hopts.Strip {
+			line = stripSensitive(line)
+		}
+		if fields := strings.Fields(line); len(fields) < 2 || fields[0] != hopts.Program {
+			continue
+		}
+		line = strings.Join(strings.Fields(line), " ")
+		if counts[line] == 0 {
+			order = append(order, line)
+		}
+		counts[line]++
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("%s: no %s commands found", path, hopts.Program)
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return counts[order[i]] > counts[order[j]] })
+	if hopts.Top > 0 && len(order) > hopts.Top {
+		order = order[:hopts.Top]
+	}
+	cmds := make([]GitCmd, len(order))
+	for i, code := range order {
+		cmds[i] = GitCmd{
+			Code:        code,
+			Description: fmt.Sprintf("Run %d time(s).", counts[code]),
+			Category:    "History",
+		}
+	}
+	return cmds, nil
+}
+
+// findHistoryFile returns the first of defaultHistoryFiles in the home directory.
+func findHistoryFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	var tried []string
+	for _, name := range defaultHistoryFiles {
+		path := filepath.Join(home, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		tried = append(tried, path)
+	}
+	return "", fmt.Errorf("no shell history found (tried %s); pass its path to -from-history", strings.Join(tried, ", "))
+}
+
+// historyCommand returns the command of one history line, dropping the
+// ": <time>:<duration>;" prefix zsh's extended history adds.
+func historyCommand(line string) string {
+	if strings.HasPrefix(line, ": ") {
+		if i := strings.IndexByte(line, ';'); i >= 0 {
+			line = line[i+1:]
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+// stripSensitive cuts a command off before its first argument that may hold
+// a secret or personal text: anything quoted, a URL, an email-like user@host
+// or a key=value pair. An option left without its value is dropped too, so
+// `git commit -m "msg"` becomes `git commit`.
+func stripSensitive(line string) string {
+	fields := strings.Fields(line)
+	for i, f := range fields {
+		if strings.ContainsAny(f, `"'=@`) || strings.Contains(f, "://") {
+			fields = fields[:i]
+			break
+		}
+	}
+	if n := len(fields); n > 2 && strings.HasPrefix(fields[n-1], "-") {
+		fields = fields[:n-1]
+	}
+	return strings.Join(fields, " ")
+}
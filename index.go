@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/boombuler/barcode/qr"
+	"github.com/fogleman/gg"
+	"github.com/makiuchi-d/gozxing"
+	multiqr "github.com/makiuchi-d/gozxing/multi/qrcode"
+)
+
+// indexMagic starts the header line of every index QR:
+//
+//	GBSIDX1 <n>/<total> <crc32 of the whole payload, hex>
+//
+// followed by a newline and the n-th piece of the command list as JSON.
+// The checksum ties the pieces of one list together, so pieces of different
+// sheets are never mixed when decoding.
+const indexMagic = "GBSIDX1"
+
+// indexChunkBytes is the most payload one index QR carries. It keeps each
+// code around version 24, which stays readable at the size drawn on A5.
+const indexChunkBytes = 900
+
+// indexChunks encodes cmds as JSON, in the command file format, split into
+// the texts of the index QRs. Pieces break between runes.
+func indexChunks(cmds []GitCmd) ([]string, error) {
+	data, err := json.Marshal(cmds)
+	if err != nil {
+		return nil, err
+	}
+	sum := crc32.ChecksumIEEE(data)
+
+	var pieces [][]byte
+	for len(data) > 0 {
+		k := min(len(data), indexChunkBytes)
+		for k < len(data) && !utf8.RuneStart(data[k]) {
+			k--
+		}
+		pieces = append(pieces, data[:k])
+		data = data[k:]
+	}
+	chunks := make([]string, len(pieces))
+	for i, p := range pieces {
+		chunks[i] = fmt.Sprintf("%s %d/%d %08x\n%s", indexMagic, i+1, len(pieces), sum, p)
+	}
+	return chunks, nil
+}
+
+// indexGrid is how many index QRs fit across and down a page.
+func indexGrid(opts Options) (cols, rows int) {
+	if w, h := opts.pagePx(); w > h {
+		return 3, 2
+	}
+	return 2, 3
+}
+
+// indexPages splits chunks into the chunks of each index page.
+func indexPages(chunks []string, opts Options) [][]string {
+	cols, rows := indexGrid(opts)
+	var pages [][]string
+	for n := cols * rows; len(chunks) > 0; {
+		k := min(n, len(chunks))
+		pages = append(pages, chunks[:k])
+		chunks = chunks[k:]
+	}
+	return pages
+}
+
+// drawIndexPage draws one page of index QRs; first is the 1-based number of
+// its first chunk and total the number of chunks overall.
+func drawIndexPage(dc canvas, chunks []string, first, total int, opts Options) error {
+	width, height := opts.pagePx()
+	margin := opts.px(60)
+	cols, rows := indexGrid(opts)
+
+	if _, err := drawHeaderStrip(dc, opts.headerStrip(), opts); err != nil {
+		log.Printf("QR error for header: %v", err)
+	}
+	top := opts.headerStrip()
+
+	dc.SetColor(color.Black)
+	band := appendixTitleBand(dc, opts)
+	dc.DrawStringAnchored("Command index – decode with -decode-index", float64(width)/2, top+margin/2+band/2, 0.5, 0.5)
+
+	dc.SetFont(opts.font(opts.fontSize(0, 24)))
+	caption := dc.FontHeight() * 2
+	cw := (float64(width) - 2*margin) / float64(cols)
+	ch := (float64(height) - 2*margin - band - top) / float64(rows)
+	size := int(min(cw, ch-caption) * 0.9)
+	for i, chunk := range chunks {
+		x := margin + float64(i%cols)*cw
+		y := top + margin + band + float64(i/cols)*ch
+		bc, err := encodeQR(chunk, size, qr.M)
+		if err != nil {
+			return fmt.Errorf("index QR %d: %w", first+i, err)
+		}
+		w := float64(bc.Bounds().Dx())
+		dc.DrawBarcode(bc, opts.snap(x+(cw-w)/2), opts.snap(y))
+		dc.DrawStringAnchored(fmt.Sprintf("%d/%d", first+i, total), x+cw/2, y+w+caption/2, 0.5, 0.5)
+	}
+	return nil
+}
+
+// drawIndex draws the index pages for cmds, each on the canvas newPage returns.
+func drawIndex(cmds []GitCmd, opts Options, newPage func() canvas) error {
+	chunks, err := indexChunks(cmds)
+	if err != nil {
+		return err
+	}
+	first := 1
+	for _, page := range indexPages(chunks, opts) {
+		if err := drawIndexPage(newPage(), page, first, len(chunks), opts); err != nil {
+			return err
+		}
+		first += len(page)
+	}
+	return nil
+}
+
+// renderIndex draws the index pages for cmds as raster pages.
+func renderIndex(cmds []GitCmd, opts Options) ([]*gg.Context, error) {
+	if err := opts.checkPixels(); err != nil {
+		return nil, err
+	}
+	width, height := opts.pagePx()
+	var out []*gg.Context
+	err := drawIndex(cmds, opts, func() canvas {
+		dc := gg.NewContext(width, height)
+		dc.SetRGB(1, 1, 1)
+		dc.Clear()
+		out = append(out, dc)
+		return &rasterCanvas{dc}
+	})
+	return out, err
+}
+
+// decodeIndex reads the index QRs in the given images, which may hold any
+// number of them in any order, and reassembles the command list JSON. It
+// fails naming the missing pieces if the images don't hold all of them.
+func decodeIndex(paths []string) ([]byte, error) {
+	var imgs []image.Image
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", path, err)
+		}
+		imgs = append(imgs, img)
+	}
+	return joinIndex(imgs)
+}
+
+// joinIndex is decodeIndex on decoded images.
+func joinIndex(imgs []image.Image) ([]byte, error) {
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	pieces := map[int]string{}
+	var total int
+	var sum uint32
+	for _, img := range imgs {
+		bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+		if err != nil {
+			return nil, err
+		}
+		found, _ := multiqr.NewQRCodeMultiReader().DecodeMultiple(bmp, hints)
+		for _, res := range found {
+			header, payload, ok := strings.Cut(res.GetText(), "\n")
+			var n, t int
+			var s uint32
+			if !ok || !strings.HasPrefix(header, indexMagic+" ") {
+				continue // another QR on the page, e.g. the footer
+			}
+			if _, err := fmt.Sscanf(header, indexMagic+" %d/%d %08x", &n, &t, &s); err != nil || n < 1 || n > t {
+				return nil, fmt.Errorf("bad index header %q", header)
+			}
+			if total == 0 {
+				total, sum = t, s
+			} else if t != total || s != sum {
+				return nil, fmt.Errorf("index QRs from different command lists (%d pieces %08x and %d pieces %08x)", total, sum, t, s)
+			}
+			pieces[n] = payload
+		}
+	}
+	if total == 0 {
+		return nil, errors.New("no index QRs found")
+	}
+
+	var missing []string
+	var buf bytes.Buffer
+	for n := 1; n <= total; n++ {
+		p, ok := pieces[n]
+		if !ok {
+			missing = append(missing, fmt.Sprint(n))
+		}
+		buf.WriteString(p)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing index QR(s) %s of %d", strings.Join(missing, ", "), total)
+	}
+	if got := crc32.ChecksumIEEE(buf.Bytes()); got != sum {
+		return nil, fmt.Errorf("reassembled index checksum %08x, want %08x", got, sum)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"testing"
+)
+
+func TestIndexRoundTrip(t *testing.T) {
+	quietLog(t)
+	cmds := builtinCommands(t)
+	pages, err := renderIndex(cmds, testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgs := make([]image.Image, len(pages))
+	for i, page := range pages {
+		imgs[i] = page.Image()
+	}
+	data, err := joinIndex(imgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(cmds)
+	if !bytes.Equal(data, want) {
+		t.Error("index decodes to a different command list")
+	}
+}
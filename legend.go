@@ -0,0 +1,98 @@
+package main
+
+import (
+	"image/color"
+)
+
+// legendEntry is one line of the symbology legend.
+type legendEntry struct {
+	code128 bool // draws the stripes icon rather than the square one
+	text    string
+}
+
+// legendEntries explains the symbologies that appear among cmds, Code128
+// first, so a sheet of only QRs doesn't describe stripes it never shows.
+func legendEntries(cmds []GitCmd, opts Options) []legendEntry {
+	var bars, squares bool
+	for _, cmd := range cmds {
+		if opts.isCode128(cmd) {
+			bars = true
+		} else {
+			squares = true
+		}
+	}
+	var out []legendEntry
+	if bars {
+		out = append(out, legendEntry{true, "Wide stripes: scan with a barcode scanner"})
+	}
+	if squares {
+		text := "Square: scan with a barcode scanner or phone camera"
+		if opts.QRMode == qrModeDocs {
+			text = "Square: scan with a phone camera to open the docs"
+		}
+		out = append(out, legendEntry{false, text})
+	}
+	return out
+}
+
+// drawLegend draws the -symbology-legend entries for cmds left-aligned at x,
+// centred on y, in the footer text colour and size, shrunk to fit maxWidth.
+// It returns the box it drew in.
+func drawLegend(dc canvas, cmds []GitCmd, x, y, maxWidth float64, opts Options) rect {
+	entries := legendEntries(cmds, opts)
+	if len(entries) == 0 {
+		return rect{}
+	}
+	dc.SetColor(opts.FooterTextColor)
+	if opts.FooterTextColor == nil {
+		dc.SetColor(color.Black)
+	}
+
+	// Each line is an icon as tall as the text, a gap, then the text.
+	var icon, gap, textW float64
+	for size := opts.fontSize(opts.FooterTextSize, 12); ; size *= 0.95 {
+		dc.SetFont(opts.font(size))
+		icon = dc.FontHeight()
+		gap = icon / 2
+		textW = 0
+		for _, e := range entries {
+			w, _ := dc.MeasureString(e.text)
+			textW = max(textW, w)
+		}
+		if 2*icon+gap+textW <= maxWidth || size < 1 {
+			break
+		}
+	}
+	lineH := icon * 1.4
+	box := rect{x, y - lineH*float64(len(entries))/2, 2*icon + gap + textW, lineH * float64(len(entries))}
+	for i, e := range entries {
+		ly := box.Y + lineH*float64(i) + (lineH-icon)/2
+		if e.code128 {
+			drawStripesIcon(dc, x, ly, 2*icon, icon)
+		} else {
+			drawSquareIcon(dc, x+icon/2, ly, icon)
+		}
+		dc.DrawStringAnchored(e.text, x+2*icon+gap, ly+icon/2, 0, 0.5)
+	}
+	return box
+}
+
+// drawStripesIcon draws a few bars of uneven width in the w x h box at (x, y).
+func drawStripesIcon(dc canvas, x, y, w, h float64) {
+	unit := w / 16
+	for _, bar := range [][2]float64{{0, 1}, {2, 2}, {5, 1}, {7, 3}, {11, 1}, {13, 2}, {15, 1}} {
+		dc.FillRect(x+bar[0]*unit, y, bar[1]*unit, h)
+	}
+}
+
+// drawSquareIcon draws a size square with the QR's three corner finder
+// patterns at (x, y).
+func drawSquareIcon(dc canvas, x, y, size float64) {
+	f := size * 3 / 8
+	dc.SetLineWidth(size / 16)
+	for _, c := range [][2]float64{{0, 0}, {size - f, 0}, {0, size - f}} {
+		dc.StrokeRect(x+c[0]+size/32, y+c[1]+size/32, f-size/16, f-size/16)
+		dc.FillRect(x+c[0]+f/4, y+c[1]+f/4, f/2, f/2)
+	}
+	dc.FillRect(x+size*0.6, y+size*0.6, size*0.25, size*0.25)
+}
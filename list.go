@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+)
+
+// Page layouts selectable with -layout.
+const (
+	layoutGrid = "grid"
+	layoutList = "list"
+)
+
+// listColumn is one column of the list layout.
+type listColumn struct {
+	el     string  // cellBarcode, cellLabel or cellDesc
+	title  string  // header row text
+	weight float64 // share of the row width
+}
+
+// listColumns returns the columns shown for opts, left to right.
+// Under DescColumn the description takes DescColumnWidth of the row and the
+// other columns share the rest in their usual proportion.
+func listColumns(opts Options) []listColumn {
+	cols := []listColumn{{cellBarcode, "Barcode", 0.4}}
+	if !opts.NoText {
+		cols = append(cols, listColumn{cellLabel, "Command", 0.25})
+		if !opts.NoDesc {
+			if opts.DescColumn {
+				f := opts.DescColumnWidth
+				cols[0].weight, cols[1].weight = 0.4/0.65*(1-f), 0.25/0.65*(1-f)
+				cols = append(cols, listColumn{cellDesc, "Description", f})
+			} else {
+				cols = append(cols, listColumn{cellDesc, "Description", 0.35})
+			}
+		}
+	}
+	return cols
+}
+
+// drawList draws cmds one per row filling area, with the barcode, label and
+// description side by side like a printed table. With opts.HeaderRow the
+// column titles are drawn in bold above the first row.
+func drawList(dc canvas, cmds []GitCmd, area rect, opts Options, report *renderReport) error {
+	cols := listColumns(opts)
+	var weights float64
+	for _, c := range cols {
+		weights += c.weight
+	}
+	xs := make([]float64, len(cols))
+	ws := make([]float64, len(cols))
+	x := area.X
+	for i, c := range cols {
+		xs[i], ws[i] = x, area.W*c.weight/weights
+		x += ws[i]
+	}
+
+	pad := opts.px(8)
+	// Sized for rows sharing the whole area; the header row takes a little.
+	labelSize, descSize := opts.cellFontSizes(area.H / float64(len(cmds)))
+	const descSpacing = 1.4
+
+	top := area.Y
+	if opts.HeaderRow {
+		dc.SetColor(color.Black)
+		dc.SetFont(opts.boldFont(labelSize))
+		h := dc.FontHeight() + 2*pad
+		for i, c := range cols {
+			if c.el == cellBarcode {
+				dc.DrawStringAnchored(c.title, xs[i]+ws[i]/2, top+h/2, 0.5, 0.5)
+			} else {
+				dc.DrawStringAnchored(c.title, xs[i]+pad, top+h/2, 0, 0.5)
+			}
+		}
+		dc.SetLineWidth(opts.px(2))
+		dc.DrawLine(area.X, top+h, area.X+area.W, top+h)
+		top += h
+	}
+	rowHeight := (area.Y + area.H - top) / float64(len(cmds))
+	if opts.Balance {
+		opts.balance = balanceSizes(cmds, ws[0], rowHeight, opts)
+	}
+
+	for i, cmd := range cmds {
+		y := top + float64(i)*rowHeight
+		row := rect{area.X, y, area.W, rowHeight}
+
+		// Light rule under each row
+		dc.SetLineWidth(opts.px(0.6))
+		dc.SetColor(color.RGBA{R: 220, G: 220, B: 220, A: 255})
+		dc.DrawLine(row.X, row.Y+row.H, row.X+row.W, row.Y+row.H)
+
+		scaled, err := encodeCell(cmd, ws[0], rowHeight, opts)
+		if err != nil {
+			log.Printf("Barcode error for %q: %v", cmd.Code, err)
+			report.Cells = append(report.Cells, cellReport{Cmd: cmd, Cell: row, Err: err})
+			continue
+		}
+
+		boxes := map[string]rect{}
+		dc.SetColor(color.Black)
+		for j, c := range cols {
+			cell := rect{xs[j], y, ws[j], rowHeight}
+			switch c.el {
+			case cellBarcode:
+				w, h := float64(scaled.Bounds().Dx()), float64(scaled.Bounds().Dy())
+				bx := opts.snap(cell.X + (cell.W-w)/2)
+				by := opts.snap(cell.Y + (cell.H-h)/2)
+				dc.DrawBarcode(scaled, bx, by)
+				boxes[cellBarcode] = rect{bx, by, w, h}
+				drawCellLogo(dc, cmd, boxes[cellBarcode], opts)
+			case cellLabel:
+				// The practice checkbox leads the command column, a label
+				// line high, and the label moves right of it.
+				if opts.Practice {
+					dc.SetFont(opts.font(labelSize))
+					side := dc.FontHeight()
+					box := rect{cell.X + pad, cell.Y + (cell.H-side)/2, side, side}
+					dc.SetLineWidth(opts.px(2))
+					dc.StrokeRect(box.X, box.Y, box.W, box.H)
+					boxes[cellCheckbox] = box
+					cell.X, cell.W = cell.X+side+pad, cell.W-side-pad
+				}
+				label := opts.label(cmd)
+				if label == "" {
+					continue
+				}
+				lines := splitLines(label)
+				maxW := opts.labelMaxWidth(cell.W, pad)
+				dc.SetFont(opts.font(opts.fitLabelSize(dc, lines, labelSize, maxW)))
+				lines = ellipsizeLines(dc, lines, maxW)
+				w, h := measureLines(dc, lines, labelSpacing)
+				ly := cell.Y + (cell.H-h)/2
+				drawLines(dc, lines, cell.X+pad, ly, 0, labelSpacing)
+				boxes[cellLabel] = rect{cell.X + pad, ly, w, h}
+			case cellDesc:
+				box, err := drawListDesc(dc, cmd, cell, pad, descSize, descSpacing, opts)
+				if err != nil {
+					return err
+				}
+				boxes[cellDesc] = box
+			}
+		}
+		if box, ok := drawCellNumber(dc, cmd, row, pad, opts); ok {
+			boxes[cellNumber] = box
+		}
+		report.Cells = append(report.Cells, cellReport{Cmd: cmd, Cell: row, Elements: boxes})
+	}
+	return nil
+}
+
+// drawListDesc draws cmd's description left-aligned and vertically centered
+// in cell, applying opts.OverflowPolicy when it is taller than the row.
+func drawListDesc(dc canvas, cmd GitCmd, cell rect, pad, size, spacing float64, opts Options) (rect, error) {
+	width := cell.W - 2*pad
+	var lines []string
+	var h float64
+	wrap := func(textScale float64) {
+		dc.SetFont(opts.font(size * textScale))
+		lines = dc.WordWrap(cmd.Description, width)
+		h = wrappedHeight(dc, len(lines), spacing)
+	}
+	wrap(1)
+
+	if avail := cell.H - 2*pad; h > avail {
+		switch opts.OverflowPolicy {
+		case overflowShrink:
+			for textScale := 0.95; h > avail && textScale > 0 && opts.aboveFontFloor(size*textScale); textScale -= 0.05 {
+				wrap(textScale)
+			}
+			if h > avail {
+				log.Printf("warning: %q still overflows its row by %.0fpx at the minimum font size", cmd.Code, h-avail)
+			}
+		case overflowError:
+			return rect{}, fmt.Errorf("%w: %q needs %.0fpx, row has %.0fpx", errCellOverflow, cmd.Code, h, avail)
+		default:
+			log.Printf("warning: %q overflows its row by %.0fpx", cmd.Code, h-avail)
+		}
+	}
+
+	w, _ := measureLines(dc, lines, spacing)
+	y := cell.Y + (cell.H-h)/2
+	drawLines(dc, lines, cell.X+pad, y, 0, spacing)
+	return rect{cell.X + pad, y, w, h}, nil
+}
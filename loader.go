@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadCommands reads a list of commands from path, or from stdin when path is
+// "-". Files ending in .yaml or .yml are YAML, so multi-line snippets can be
+// written as block scalars; anything else is a JSON array. Each entry needs at
+// least a "code". With trim, leading and trailing whitespace is cut from each
+// code, since a stray space gets scanned with it, and the entries changed are
+// logged, bar the single trailing newline a YAML block scalar ends in.
+func loadCommands(path string, trim bool) ([]GitCmd, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var cmds []GitCmd
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.NewDecoder(r).Decode(&cmds); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	default:
+		if err := json.NewDecoder(r).Decode(&cmds); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	if trim {
+		var trimmed []string
+		for i, cmd := range cmds {
+			code := strings.TrimSpace(cmd.Code)
+			// A YAML | block scalar always ends in one newline; chomping it
+			// is routine, so only other whitespace is worth a warning.
+			if code != strings.TrimSuffix(cmd.Code, "\n") {
+				trimmed = append(trimmed, fmt.Sprintf("entry %d %q", i+1, cmd.Code))
+			}
+			cmds[i].Code = code
+		}
+		if len(trimmed) > 0 {
+			log.Printf("warning: %s: trimmed whitespace around %d code(s) (-no-trim keeps it): %s", path, len(trimmed), strings.Join(trimmed, ", "))
+		}
+	}
+	for i, cmd := range cmds {
+		if cmd.Code == "" {
+			return nil, fmt.Errorf("%s: entry %d has no code", path, i+1)
+		}
+		if _, err := cmd.payload(); cmd.Base64 && err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", path, i+1, err)
+		}
+		if err := validateEncodeOptions(cmd.EncodeOptions); err != nil {
+			return nil, fmt.Errorf("%s: entry %d encode_options: %w", path, i+1, err)
+		}
+		if cmd.DescWidth < 0 {
+			return nil, fmt.Errorf("%s: entry %d has a negative desc_width", path, i+1)
+		}
+	}
+	return cmds, nil
+}
+
+// enabledCommands returns cmds without the disabled entries and how many were dropped.
+func enabledCommands(cmds []GitCmd) ([]GitCmd, int) {
+	var out []GitCmd
+	for _, cmd := range cmds {
+		if !cmd.Disabled {
+			out = append(out, cmd)
+		}
+	}
+	return out, len(cmds) - len(out)
+}
+
+// descriptionEntry is one value of a -descriptions file: a description, or an
+// object with a description and label.
+type descriptionEntry struct {
+	Description string `json:"description" yaml:"description"`
+	Label       string `json:"label,omitempty" yaml:"label,omitempty"`
+}
+
+func (d *descriptionEntry) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &d.Description); err == nil {
+		return nil
+	}
+	type plain descriptionEntry
+	return json.Unmarshal(data, (*plain)(d))
+}
+
+func (d *descriptionEntry) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&d.Description)
+	}
+	type plain descriptionEntry
+	return node.Decode((*plain)(d))
+}
+
+// loadDescriptions reads a -descriptions file: a JSON object (YAML mapping
+// for .yaml and .yml) from code to description, or to an object with a
+// "description" and "label", so descriptions can be kept and translated
+// apart from the command list.
+func loadDescriptions(path string) (map[string]descriptionEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var descs map[string]descriptionEntry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &descs)
+	default:
+		err = json.Unmarshal(data, &descs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return descs, nil
+}
+
+// applyDescriptions returns a copy of cmds with the descriptions, and labels
+// where given, of the entries in descs whose code matches. It logs the
+// entries that match no command, most likely typos or stale codes.
+func applyDescriptions(cmds []GitCmd, descs map[string]descriptionEntry, path string) []GitCmd {
+	out := make([]GitCmd, len(cmds))
+	used := map[string]bool{}
+	for i, cmd := range cmds {
+		if d, ok := descs[cmd.Code]; ok {
+			used[cmd.Code] = true
+			if d.Description != "" {
+				cmd.Description = d.Description
+			}
+			if d.Label != "" {
+				cmd.Label = d.Label
+			}
+		}
+		out[i] = cmd
+	}
+	var unused []string
+	for code := range descs {
+		if !used[code] {
+			unused = append(unused, fmt.Sprintf("%q", code))
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		log.Printf("warning: %s: %d description(s) match no command: %s", path, len(unused), strings.Join(unused, ", "))
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadCommandsTrim(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		body     string
+		trim     bool
+		want     []string
+		wantWarn string // "" for no warning
+	}{
+		{
+			name: "yaml block scalar",
+			file: "cmds.yaml",
+			body: "- code: |\n    git fetch\n    git status -sb\n- code: git status\n",
+			trim: true,
+			want: []string{"git fetch\ngit status -sb", "git status"},
+		},
+		{
+			name:     "yaml keep block scalar",
+			file:     "cmds.yaml",
+			body:     "- code: |+\n    git fetch\n\n",
+			trim:     true,
+			want:     []string{"git fetch"},
+			wantWarn: "trimmed whitespace around 1 code(s)",
+		},
+		{
+			name:     "stray spaces",
+			file:     "cmds.json",
+			body:     `[{"code": " git status "}, {"code": "git log\t"}]`,
+			trim:     true,
+			want:     []string{"git status", "git log"},
+			wantWarn: `trimmed whitespace around 2 code(s) (-no-trim keeps it): entry 1 " git status ", entry 2 "git log\t"`,
+		},
+		{
+			name:     "block scalar with trailing spaces",
+			file:     "cmds.yml",
+			body:     "- code: \"git fetch \\n\"\n",
+			trim:     true,
+			want:     []string{"git fetch"},
+			wantWarn: "entry 1",
+		},
+		{
+			name: "no trim",
+			file: "cmds.yaml",
+			body: "- code: |\n    git fetch\n",
+			want: []string{"git fetch\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.body), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			var logged bytes.Buffer
+			w := log.Writer()
+			log.SetOutput(&logged)
+			defer log.SetOutput(w)
+
+			cmds, err := loadCommands(path, tt.trim)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, cmd := range cmds {
+				got = append(got, cmd.Code)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("codes = %q, want %q", got, tt.want)
+			}
+			switch {
+			case tt.wantWarn == "" && logged.Len() > 0:
+				t.Errorf("unexpected warning: %s", logged.String())
+			case tt.wantWarn != "" && !strings.Contains(logged.String(), tt.wantWarn):
+				t.Errorf("warning %q doesn't contain %q", logged.String(), tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestDescriptionsMatchPlaceholders(t *testing.T) {
+	cmds := expandPlaceholders([]GitCmd{
+		{Code: "git push --set-upstream {{remote}} HEAD", Description: "Push and set upstream."},
+		{Code: "git fetch {{remote}}", Description: "Fetch."},
+		{Code: "git status", Description: "Status."},
+	}, "upstream")
+	tests := []struct {
+		name     string
+		descs    map[string]descriptionEntry
+		want     []string
+		wantWarn bool
+	}{
+		{
+			name:  "expanded key",
+			descs: map[string]descriptionEntry{"git push --set-upstream upstream HEAD": {Description: "Hochladen."}},
+			want:  []string{"Hochladen.", "Fetch.", "Status."},
+		},
+		{
+			name:  "placeholder key and text",
+			descs: map[string]descriptionEntry{"git fetch {{remote}}": {Description: "Von {{remote}} holen."}},
+			want:  []string{"Push and set upstream.", "Von upstream holen.", "Status."},
+		},
+		{
+			name: "both forms, spelled out wins",
+			descs: map[string]descriptionEntry{
+				"git fetch {{remote}}": {Description: "placeholder"},
+				"git fetch upstream":   {Description: "spelled out"},
+			},
+			want: []string{"Push and set upstream.", "spelled out", "Status."},
+		},
+		{
+			name:     "other remote",
+			descs:    map[string]descriptionEntry{"git fetch origin": {Description: "Fetch origin."}},
+			want:     []string{"Push and set upstream.", "Fetch.", "Status."},
+			wantWarn: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged bytes.Buffer
+			w := log.Writer()
+			log.SetOutput(&logged)
+			defer log.SetOutput(w)
+
+			got := applyDescriptions(cmds, expandDescriptions(tt.descs, "upstream"), "descs.json")
+			for i, cmd := range got {
+				if cmd.Description != tt.want[i] {
+					t.Errorf("%q described %q, want %q", cmd.Code, cmd.Description, tt.want[i])
+				}
+			}
+			if warned := strings.Contains(logged.String(), "match no command"); warned != tt.wantWarn {
+				t.Errorf("warned %v, want %v: %s", warned, tt.wantWarn, logged.String())
+			}
+		})
+	}
+}
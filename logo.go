@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // logos may be JPEG
+	"os"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+// qrLevels are the -qr-ec error correction levels, recovering about 7%, 15%,
+// 25% and 30% of a damaged code.
+var qrLevels = map[string]qr.ErrorCorrectionLevel{
+	"L": qr.L,
+	"M": qr.M,
+	"Q": qr.Q,
+	"H": qr.H,
+}
+
+// qrLogoFractions are the widths of a QR logo as a fraction of the QR's, per
+// error correction level. Level H, which -qr-logo forces, gets the ~15% the
+// logo is meant to be; a command's own lower qr.ec shrinks it so that, with
+// its white pad, the code still decodes.
+var qrLogoFractions = map[qr.ErrorCorrectionLevel]float64{
+	qr.L: 0.05,
+	qr.M: 0.10,
+	qr.Q: 0.12,
+	qr.H: 0.15,
+}
+
+// footerQRLevel is the error correction of the footer QR: H under a logo,
+// else o.QRLevel.
+func (o Options) footerQRLevel() qr.ErrorCorrectionLevel {
+	if o.QRLogo != nil {
+		return qr.H
+	}
+	return o.QRLevel
+}
+
+// loadLogo reads the image file at path.
+func loadLogo(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// drawCellLogo draws opts.QRLogo over a cell's QR in box under QRLogoCells.
+func drawCellLogo(dc canvas, cmd GitCmd, box rect, opts Options) {
+	if opts.QRLogo != nil && opts.QRLogoCells && !opts.isCode128(cmd) {
+		level, _ := opts.qrSettings(cmd)
+		drawQRLogo(dc, box, opts.QRLogo, level)
+	}
+}
+
+// drawQRLogo draws logo over the centre of the QR drawn in box, on a white
+// pad, keeping the logo's aspect ratio within a square sized by
+// qrLogoFractions for the QR's level.
+func drawQRLogo(dc canvas, box rect, logo image.Image, level qr.ErrorCorrectionLevel) {
+	side := box.W * qrLogoFractions[level]
+	lb := logo.Bounds()
+	w, h := side, side
+	if lb.Dx() > lb.Dy() {
+		h = side * float64(lb.Dy()) / float64(lb.Dx())
+	} else {
+		w = side * float64(lb.Dx()) / float64(lb.Dy())
+	}
+	pad := side * 0.1
+	cx, cy := box.X+box.W/2, box.Y+box.H/2
+	dc.SetColor(color.White)
+	dc.FillRect(cx-w/2-pad, cy-h/2-pad, w+2*pad, h+2*pad)
+	dc.DrawImage(logo, cx-w/2, cy-h/2, w, h)
+	dc.SetColor(color.Black)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing"
+)
+
+// testLogo is a solid square, the worst case for the modules it covers.
+func testLogo() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0x20, 0x40, 0xc0, 0xff}), image.Point{}, draw.Src)
+	return img
+}
+
+func TestLogoQRsDecode(t *testing.T) {
+	quietLog(t)
+	for _, name := range []string{"L", "M", "Q", "H"} {
+		t.Run(name, func(t *testing.T) {
+			opts := testOptions()
+			opts.QRLogo, opts.QRLogoCells, opts.QRLevel = testLogo(), true, qrLevels[name]
+			dc, report, err := renderSheet(sheetPages(builtinCommands(t), opts)[0], opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img := dc.Image()
+			if res, err := decodeQR(img, report.Footer); err != nil || res.GetText() != opts.FooterURL {
+				t.Errorf("footer QR with -qr-logo does not decode: %v", err)
+			}
+			for _, cell := range report.Cells {
+				if opts.isCode128(cell.Cmd) || cell.Err != nil {
+					continue
+				}
+				raw, err := encodeRaw(cell.Cmd, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res, err := decodeQR(img, cell.Elements[cellBarcode]); err != nil || res.GetText() != raw.Content() {
+					t.Errorf("%q QR with -qr-logo does not decode: %v", cell.Cmd.Code, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLogoLevels(t *testing.T) {
+	quietLog(t)
+	cmds := sheetPages(builtinCommands(t), testOptions())[0]
+	_, plainReport, err := renderSheet(cmds, testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := testOptions()
+	opts.QRLogo = testLogo()
+	dc, report, err := renderSheet(cmds, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := decodeQR(dc.Image(), report.Footer)
+	if err != nil {
+		t.Fatalf("footer QR with -qr-logo does not decode: %v", err)
+	}
+	if got := fmt.Sprint(res.GetResultMetadata()[gozxing.ResultMetadataType_ERROR_CORRECTION_LEVEL]); got != "H" {
+		t.Errorf("footer QR under a logo at level %s, want H", got)
+	}
+	// Without -qr-logo-cells the command QRs keep -qr-ec.
+	for i, cell := range report.Cells {
+		if opts.isCode128(cell.Cmd) {
+			continue
+		}
+		if want := plainReport.Cells[i].Elements[cellBarcode]; cell.Elements[cellBarcode] != want {
+			t.Errorf("%q QR at %+v with a footer logo, %+v without", cell.Cmd.Code, cell.Elements[cellBarcode], want)
+		}
+		res, err := decodeQR(dc.Image(), cell.Elements[cellBarcode])
+		if err != nil {
+			t.Fatalf("%q QR does not decode: %v", cell.Cmd.Code, err)
+		}
+		if got := fmt.Sprint(res.GetResultMetadata()[gozxing.ResultMetadataType_ERROR_CORRECTION_LEVEL]); got != "M" {
+			t.Errorf("%q QR at level %s, want -qr-ec's M", cell.Cmd.Code, got)
+		}
+	}
+}
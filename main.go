@@ -1,262 +1,617 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"image"
 	"image/color"
+	"io"
 	"log"
-	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
 
-	"github.com/boombuler/barcode"
-	"github.com/boombuler/barcode/code128"
 	"github.com/boombuler/barcode/qr"
 	"github.com/fogleman/gg"
-	"golang.org/x/image/font"
-	"golang.org/x/image/font/gofont/goregular"
-	"golang.org/x/image/font/opentype"
 )
 
-// Scanner always appends a newline (<CR> / Enter).
-// All Code values are complete git commands and DO NOT include newline characters.
+const defaultFooterURL = "https://github.com/arran4/git-barcode-sheet"
 
-type GitCmd struct {
-	Code        string // exact text encoded in the barcode (no newline)
-	Label       string // short label under barcode
-	Description string // explanation under the label
-}
+// defaultTitle is used for command files, which carry no title of their own.
+const defaultTitle = "Command Barcode Sheet – One Scan = One Command"
 
-// 40 git CLI commands -> 4 x 10 grid, all self-contained (no editing needed).
-var Commands = []GitCmd{
-	// --- Status / inspection ---
-	{"git status", "git status", "Show working tree status."},
-	{"git status -sb", "git status -sb", "Short, branch-aware status."},
-	{"git diff", "git diff", "Diff unstaged changes."},
-	{"git diff --staged", "git diff --staged", "Diff staged changes."},
-
-	// --- Staging / restoring ---
-	{"git add .", "git add .", "Stage all changes in current repo."},
-	{"git add -p", "git add -p", "Interactive patch staging."},
-	{"git restore .", "git restore .", "Discard unstaged changes in files."},
-	{"git restore --staged .", "git restore --staged .", "Unstage all changes."},
-
-	// --- Common commit messages ---
-	{"git commit -m \"Initial commit\"", "Initial commit", "Create an initial commit."},
-	{"git commit -m \"Update README\"", "Update README", "Commit README changes."},
-	{"git commit -m \"Fix bug\"", "Fix bug", "Commit a bugfix."},
-	{"git commit -m \"Refactor code\"", "Refactor code", "Commit refactor changes."},
-
-	// --- Generic commit / log helpers ---
-	{"git commit -m \"WIP\"", "WIP commit", "Quick work-in-progress commit."},
-	{"git log --oneline --graph --decorate --all", "Pretty log", "Compact decorated log graph."},
-	{"git log --oneline", "Log oneline", "Short one-line commit history."},
-	{"git show", "git show", "Show details of the latest commit."},
-
-	// --- Stash ---
-	{"git stash", "git stash", "Stash uncommitted changes."},
-	{"git stash pop", "stash pop", "Apply and drop latest stash."},
-	{"git stash list", "stash list", "List all stashes."},
-	{"git stash drop", "stash drop", "Drop latest stash."},
-
-	// --- Branching & navigation ---
-	{"git branch", "git branch", "List local branches."},
-	{"git branch -vv", "git branch -vv", "Branches with tracking info."},
-	{"git checkout -", "git checkout -", "Switch to previous branch."},
-	{"git reflog", "git reflog", "Show reference log for HEAD history."},
-
-	// --- Sync / remotes ---
-	{"git fetch --all --prune", "fetch --all", "Fetch all remotes and prune."},
-	{"git pull", "git pull", "Pull from current upstream."},
-	{"git push", "git push", "Push current HEAD to upstream."},
-	{"git push --set-upstream origin HEAD", "push -u origin HEAD", "Push and set upstream."},
-
-	// --- Tags / metadata ---
-	{"git tag", "git tag", "List tags."},
-	{"git tag -l", "git tag -l", "List tags (pattern-capable)."},
-	{"git remote -v", "git remote -v", "List remotes and URLs."},
-	{"git config --list", "git config --list", "Show all Git config entries."},
-
-	// --- Search / history helpers ---
-	{"git grep -n \"TODO\"", "grep TODO", "Search TODO in tracked files."},
-	{"git shortlog -sn", "shortlog -sn", "Author summary (commits per author)."},
-	{"git rev-parse --show-toplevel", "repo root", "Show path to repo root."},
-	{"git rev-parse --abbrev-ref HEAD", "current branch", "Show current branch name."},
-
-	// --- Cleanup / caution ---
-	{"git status --ignored", "status ignored", "Status including ignored files."},
-	{"git diff --stat", "diff --stat", "Diff summary (per-file stats)."},
-	{"git clean -fd", "clean -fd", "Danger: remove untracked files & dirs."},
-	{"git submodule update --init --recursive", "submodules", "Init and update submodules."},
-}
+func main() {
+	opts := Options{}
+	flag.Float64Var(&opts.DPI, "dpi", 300, "output resolution in dots per inch")
+	flag.IntVar(&opts.Cols, "cols", 4, "grid columns (0 = choose automatically from -min-module-mm / -bar-height-mm)")
+	flag.Float64Var(&opts.BarHeightMM, "bar-height-mm", 0, "Code128 bar height in millimetres (0 = fraction of the cell)")
+	flag.Float64Var(&opts.MinModuleMM, "min-module-mm", 0, "minimum module width in millimetres required by the scanner (0 = no check)")
+	flag.IntVar(&opts.MaxModulePx, "max-module-px", 0, "cap the module width in pixels, leaving spare cell space as margin (0 = no cap)")
+	flag.Float64Var(&opts.Code128Width, "code128-width", defaultCode128Width, "Code128 width: up to 1 a fraction of the cell width, above 1 pixels")
+	flag.BoolVar(&opts.Code128Natural, "code128-natural", false, "draw Code128s at a standard bar width instead of stretching short ones across the cell")
+	flag.StringVar(&opts.FontUnits, "font-units", fontUnitsPx, "font size units: px (fixed pixels) or pt (points on paper, scaled by -dpi)")
+	flag.Float64Var(&opts.TitleSize, "title-size", 0, "title font size in -font-units (0 = built-in)")
+	flag.Float64Var(&opts.LabelSize, "label-size", 0, "label font size in -font-units (0 = built-in)")
+	flag.Float64Var(&opts.DescSize, "desc-size", 0, "description font size in -font-units (0 = built-in)")
+	flag.StringVar(&opts.Layout, "layout", layoutGrid, "page layout: grid, or list for one command per row")
+	flag.StringVar(&opts.FillOrder, "fill-order", fillLTR, "direction grid and label rows fill in: ltr, or rtl for right to left")
+	flag.BoolVar(&opts.HeaderRow, "header-row", false, "list layout: draw bold column titles above the first row")
+	flag.BoolVar(&opts.AutoFont, "auto-font", false, "size labels and descriptions as a fraction of the cell height, between -min-font-size and 24pt (explicit -label-size / -desc-size still win)")
+	flag.Float64Var(&opts.LabelMaxWidth, "label-max-width", 0, "widest a label may be: up to 1 a fraction of the cell width, above 1 pixels (0 = cell width); longer labels shrink to -min-font-size, then end in …")
+	flag.StringVar(&opts.EmptyLabel, "empty-label", emptyLabelCode, "label for commands without one: code, none, or subcommand for the first two words of the code")
+	flag.Float64Var(&opts.MinFontSize, "min-font-size", 7, "smallest size in points that auto-shrinking text may reach")
+	flag.BoolVar(&opts.CutGuides, "cut-guides", false, "draw edge-to-edge cut lines at the grid's cell boundaries")
+	cutGuideColor := flag.String("cut-guide-color", "#808080", "colour of -cut-guides as #rrggbb")
+	flag.BoolVar(&opts.CropMarks, "crop-marks", false, "draw trim marks at the grid's corners, in the page margin")
+	flag.Float64Var(&opts.CropMarkOffsetMM, "crop-mark-offset", 1, "gap in millimetres between the grid's corners and -crop-marks")
+	flag.Float64Var(&opts.CropMarkLengthMM, "crop-mark-length", 3, "length in millimetres of -crop-marks, shortened to fit the margin")
+	flag.Float64Var(&opts.CutGuideWidth, "cut-guide-width", 1, "width of -cut-guides in pixels")
+	flag.Int64Var(&opts.MaxPixels, "max-pixels", defaultMaxPixels, "refuse raster pages larger than this many pixels (0 = no limit)")
+	flag.BoolVar(&opts.Appendix, "appendix", false, "add pages listing every command's exact code in monospace, grouped by category, for typing without a scanner")
+	flag.BoolVar(&opts.Index, "index", false, "add pages of QR codes encoding the whole command list as JSON, recoverable with -decode-index")
+	flag.BoolVar(&opts.DescColumn, "desc-column", false, "put descriptions in a left-aligned column right of the barcode instead of under it")
+	flag.Float64Var(&opts.DescColumnWidth, "desc-column-width", 0.5, "share of the cell (or list row) width given to the -desc-column descriptions, between 0 and 1")
+	flag.BoolVar(&opts.Numbered, "numbered", false, "draw each command's sequence number in a corner of its cell, counting on across pages")
+	flag.StringVar(&opts.NumberCorner, "number-corner", cornerTopRight, "corner for -numbered: top-left, top-right, bottom-left or bottom-right")
+	flag.Float64Var(&opts.NumberSize, "number-size", 0, "-numbered font size in -font-units (0 = built-in)")
+	flag.BoolVar(&opts.NoFooter, "no-footer", false, "leave off the footer QR and URL and let the grid use the bottom margin")
+	flag.BoolVar(&opts.SymbologyLegend, "symbology-legend", false, "explain in the footer which scanners read the sheet's wide stripes and squares")
+	flag.BoolVar(&opts.RepeatFooterAsHeader, "repeat-footer-as-header", false, "draw the footer QR and URL as a compact header strip on every page after the first")
+	flag.BoolVar(&opts.Snap, "snap", false, "place barcodes on whole pixels so bar edges stay crisp, also in PDF output")
+	flag.BoolVar(&opts.Balance, "balance", false, "give all Code128s and all QRs a shared module width, and bars the height of the QRs, for an even-looking grid")
+	flag.Float64Var(&opts.FooterTextSize, "footer-text-size", 0, "footer text font size in -font-units (0 = built-in); shrunk to fit the page width")
+	footerTextColor := flag.String("footer-text-color", "#000000", "footer text colour as #rrggbb")
+	flag.BoolVar(&opts.NoText, "no-text", false, "barcode-only cells: drop labels and descriptions")
+	flag.BoolVar(&opts.NoDesc, "no-desc", false, "label and barcode only: drop descriptions and give their space to the barcode")
+	flag.BoolVar(&opts.Practice, "practice", false, "draw a checkbox in each cell to tick off practised commands (ignored with -no-text)")
+	flag.StringVar(&opts.Title, "title", "", "page title (default: the command set's title)")
+	flag.StringVar(&opts.TitleOverflow, "title-overflow", titleOverflowShrink, "when the title is too wide: shrink, wrap or clip")
+	flag.StringVar(&opts.OverflowPolicy, "overflow-policy", overflowWarn, "when a cell's content is taller than the cell: shrink, warn or error")
+	flag.StringVar(&opts.OverflowCommand, "overflow-command", overflowCommandSkip, "when a command is too long for a QR code: skip, truncate or error")
+	flag.StringVar(&opts.QRMode, "qr-mode", qrModeCommand, "what QR cells encode: command, or docs (also scm-docs) for the command's documentation URL (Code128 cells always encode the command)")
+	flag.StringVar(&opts.QREncoding, "qr-encoding", "auto", "QR cell encoding mode: auto, byte, alphanumeric or numeric; a forced mode fails on commands it can't hold")
+	encodeOpts := flag.String("encode-opts", "", "encoder settings as comma-separated key=value: code128.checksum, code128.gs1, qr.ec, qr.encoding")
+	qrEC := flag.String("qr-ec", "M", "QR error correction level: L, M, Q or H (forced to H for the QRs -qr-logo is drawn on)")
+	qrLogo := flag.String("qr-logo", "", "PNG or JPEG drawn over the centre of the footer QR, at about 15% of its width")
+	flag.BoolVar(&opts.QRLogoCells, "qr-logo-cells", false, "also draw -qr-logo over every command QR")
+	allQR := flag.Bool("all-qr", false, "draw every command as a QR, for phone cameras, warning about QRs too dense to read at cell size")
+	allCode128 := flag.Bool("all-code128", false, "draw every command as Code128, for hardware scanners without QR support")
+	flag.StringVar(&opts.FooterURL, "footer-url", defaultFooterURL, "text encoded in the footer QR")
+	out := flag.String("o", "git-barcode-sheet-a4.png", "output file (.png, .jpg, .jpeg or .pdf)")
+	flag.IntVar(&opts.RotatePage, "rotate-page", 0, "turn PNG and JPEG pages clockwise by 90, 180 or 270 degrees before saving")
+	flag.BoolVar(&opts.Indexed, "indexed", false, "save PNG pages with a small palette of greys and the theme colours, for much smaller files")
+	pngCompression := flag.String("png-compression", "default", "PNG compression: default, best-speed, best-compression or no-compression")
+	footerOnly := flag.String("footer-only", "", "write only the footer QR to this image file (.ico for a 16/32/48px favicon) and skip the sheet")
+	exportDir := flag.String("export-dir", "", "write each command's barcode and label to its own file in this directory and skip the sheet")
+	exportFormat := flag.String("export-format", exportPNG, "file format of -export-dir: png or svg")
+	footerOnlySize := flag.Int("footer-size", 512, "size in pixels of the standalone footer QR (with -footer-only)")
+	nup := flag.Int("nup", 1, "tile N copies of the sheet as cut-out cards on one page (e.g. 4 = A6 cards on A4)")
+	remote := flag.String("remote", "origin", "remote name substituted for {{remote}} in commands")
+	cellOrder := flag.String("cell-order", strings.Join(defaultCellOrder, ","), "vertical order of the cell elements label, barcode and desc")
+	runSelfTest := flag.Bool("self-test", false, "render the sheet and fail if any command is skipped or drawn out of bounds")
+	verifyPhotoFile := flag.String("verify-photo", "", "decode photos of the printed sheet, one per page, comma-separated, and report which commands scan; exits non-zero if any don't")
+	runQualityReport := flag.Bool("quality-report", false, "print each barcode's module size, quiet zone and QR error correction headroom, flag those below the thresholds and exit non-zero if any")
+	qualityMinModuleMM := flag.Float64("quality-min-module-mm", 0.25, "smallest printed module width -quality-report accepts")
+	qualityMinModulePx := flag.Int("quality-min-module-px", 2, "smallest module width in output pixels -quality-report accepts")
+	decodeIndexFiles := flag.String("decode-index", "", "read the -index QRs from these comma-separated images, print the command list JSON and exit")
+	templateName := flag.String("template", "", "lay commands out on a label sheet, e.g. avery5160, avery5163, l7160 or l7163")
+	templatesFile := flag.String("templates", "", "JSON file of extra label templates for -template")
+	setName := flag.String("set", defaultSet, "built-in command set: git, docker, kubectl or npm")
+	commandsFile := flag.String("commands", "", "load commands from this JSON or YAML (.yaml/.yml) file (- for JSON on stdin) instead of a built-in set")
+	descriptionsFile := flag.String("descriptions", "", "JSON or YAML file mapping codes to descriptions (and labels) to use in place of the commands' own")
+	noTrim := flag.Bool("no-trim", false, "keep leading and trailing whitespace in -commands codes instead of trimming it")
+	fromHistory := flag.String("from-history", "", "build the sheet from your most frequent -set commands in this shell history file (auto = ~/.zsh_history or ~/.bash_history)")
+	historyTop := flag.Int("history-top", 40, "with -from-history, keep this many of the most frequent commands (0 = all)")
+	historyStrip := flag.Bool("history-strip", false, "with -from-history, cut commands off before quoted text, URLs, user@host and key=value arguments")
+	shuffle := flag.Bool("shuffle", false, "shuffle the command order so positions can't be memorised")
+	seed := flag.Int64("seed", 0, "seed for -shuffle, for a reproducible order (0 = random)")
+	printConfig := flag.Bool("print-config", false, "print the resolved options, command source and command count as JSON to stderr before rendering")
+	listBuiltin := flag.Bool("list-builtin", false, "print the built-in commands of -set grouped by category and exit")
+	flag.Parse()
+
+	if opts.FontUnits != fontUnitsPx && opts.FontUnits != fontUnitsPt {
+		log.Fatalf("invalid -font-units %q: want %s or %s", opts.FontUnits, fontUnitsPx, fontUnitsPt)
+	}
 
-// font cache so we only parse Go Regular once per size.
-var fontCache = map[float64]font.Face{}
+	switch opts.TitleOverflow {
+	case titleOverflowShrink, titleOverflowWrap, titleOverflowClip:
+	default:
+		log.Fatalf("invalid -title-overflow %q: want %s, %s or %s", opts.TitleOverflow, titleOverflowShrink, titleOverflowWrap, titleOverflowClip)
+	}
 
-func main() {
-	// A4 @ 300 DPI
-	const dpi = 300
-	const a4WidthInches = 8.27
-	const a4HeightInches = 11.69
-
-	width := int(a4WidthInches * dpi)
-	height := int(a4HeightInches * dpi)
-
-	dc := gg.NewContext(width, height)
-
-	// Background
-	dc.SetRGB(1, 1, 1)
-	dc.Clear()
-
-	// Tighter margins to reduce white space
-	margin := 60.0
-
-	// Title (larger font)
-	dc.SetColor(color.Black)
-	dc.SetFontFace(mustGoRegularFace(36))
-	title := "Git Barcode Sheet – One Scan = One Command"
-	dc.DrawStringAnchored(title, float64(width)/2, margin/2, 0.5, 0.5)
-
-	// Layout: 4 columns, N rows
-	cols := 4
-	rows := int(math.Ceil(float64(len(Commands)) / float64(cols)))
-
-	top := margin
-	bottom := float64(height) - margin
-	left := margin
-	right := float64(width) - margin
-
-	cellWidth := (right - left) / float64(cols)
-	cellHeight := (bottom - top) / float64(rows)
-
-	// In each cell:
-	// - If command is short: draw wide Code128 barcode
-	// - If command is long: draw square-ish QR
-
-	// Threshold (characters) for "short" vs "long" commands
-	const shortCmdMaxLen = 26
-
-	for i, cmd := range Commands {
-		col := i % cols
-		row := i / cols
-
-		x := left + float64(col)*cellWidth
-		y := top + float64(row)*cellHeight
-
-		cx := x + cellWidth/2
-
-		// Light cell boundary
-		dc.SetLineWidth(0.6)
-		dc.SetColor(color.RGBA{R: 220, G: 220, B: 220, A: 255})
-		dc.DrawRectangle(x, y, cellWidth, cellHeight)
-		dc.Stroke()
-
-		// --- Refactored Layout: Label -> Barcode -> Description ---
-
-		// 1. Label (common to both barcode types)
-		labelY := y + 20
-		dc.SetColor(color.Black)
-		dc.SetFontFace(mustGoRegularFace(24)) // Increased label font size
-		label := cmd.Label
-		if label == "" {
-			label = cmd.Code
-		}
-		dc.DrawStringAnchored(label, cx, labelY, 0.5, 0)
-
-		// Barcode generation (specific to type)
-		var scaled barcode.Barcode
-		var err error
-
-		if len(cmd.Code) <= shortCmdMaxLen {
-			// --- Code128 for short commands ---
-			barWidth := int(cellWidth * 0.9)
-			barHeight := int(cellHeight * 0.45)
-			raw, errEncode := code128.Encode(cmd.Code)
-			if errEncode != nil {
-				log.Printf("Code128 encode error for %q: %v", cmd.Code, errEncode)
-				continue
-			}
-			scaled, err = barcode.Scale(raw, barWidth, barHeight)
-		} else {
-			// --- QR for long commands ---
-			qrSize := int(math.Min(cellWidth*0.75, cellHeight*0.5))
-			raw, errEncode := qr.Encode(cmd.Code, qr.M, qr.Auto)
-			if errEncode != nil {
-				log.Printf("QR encode error for %q: %v", cmd.Code, errEncode)
-				continue
+	switch opts.OverflowPolicy {
+	case overflowShrink, overflowWarn, overflowError:
+	default:
+		log.Fatalf("invalid -overflow-policy %q: want %s, %s or %s", opts.OverflowPolicy, overflowShrink, overflowWarn, overflowError)
+	}
+	switch opts.OverflowCommand {
+	case overflowCommandSkip, overflowCommandTruncate, overflowCommandError:
+	default:
+		log.Fatalf("invalid -overflow-command %q: want %s, %s or %s", opts.OverflowCommand, overflowCommandSkip, overflowCommandTruncate, overflowCommandError)
+	}
+
+	if opts.Layout != layoutGrid && opts.Layout != layoutList {
+		log.Fatalf("invalid -layout %q: want %s or %s", opts.Layout, layoutGrid, layoutList)
+	}
+	if opts.HeaderRow && opts.Layout != layoutList {
+		log.Fatalf("-header-row needs -layout %s", layoutList)
+	}
+
+	switch opts.NumberCorner {
+	case cornerTopLeft, cornerTopRight, cornerBottomLeft, cornerBottomRight:
+	default:
+		log.Fatalf("invalid -number-corner %q: want %s, %s, %s or %s", opts.NumberCorner, cornerTopLeft, cornerTopRight, cornerBottomLeft, cornerBottomRight)
+	}
+	if opts.Numbered && opts.Practice && opts.NumberCorner == cornerTopLeft {
+		log.Fatalf("-number-corner %s would cover the -practice checkbox; pick another corner", cornerTopLeft)
+	}
+
+	if opts.Code128Width <= 0 {
+		log.Fatalf("invalid -code128-width %g: want a fraction of the cell width or a width in pixels", opts.Code128Width)
+	}
+
+	if *exportFormat != exportPNG && *exportFormat != exportSVG {
+		log.Fatalf("invalid -export-format %q: want %s or %s", *exportFormat, exportPNG, exportSVG)
+	}
+
+	if opts.CropMarkOffsetMM < 0 || opts.CropMarkLengthMM <= 0 {
+		log.Fatalf("invalid -crop-mark-offset %g / -crop-mark-length %g: want an offset of zero or more and a positive length", opts.CropMarkOffsetMM, opts.CropMarkLengthMM)
+	}
+
+	if opts.FillOrder != fillLTR && opts.FillOrder != fillRTL {
+		log.Fatalf("invalid -fill-order %q: want %s or %s", opts.FillOrder, fillLTR, fillRTL)
+	}
+
+	if opts.NoFooter && opts.SymbologyLegend {
+		log.Fatalf("-symbology-legend is drawn in the footer; it can't be combined with -no-footer")
+	}
+
+	switch opts.EmptyLabel {
+	case emptyLabelCode, emptyLabelNone, emptyLabelSubcommand:
+	default:
+		log.Fatalf("invalid -empty-label %q: want %s, %s or %s", opts.EmptyLabel, emptyLabelCode, emptyLabelNone, emptyLabelSubcommand)
+	}
+
+	switch opts.RotatePage {
+	case 0, 90, 180, 270:
+	default:
+		log.Fatalf("invalid -rotate-page %d: want 90, 180 or 270", opts.RotatePage)
+	}
+	if opts.RotatePage != 0 && isPDF(*out) {
+		log.Fatalf("-rotate-page applies to PNG and JPEG output; rotate PDF pages in the print dialog")
+	}
+
+	if opts.DescColumnWidth <= 0 || opts.DescColumnWidth >= 1 {
+		log.Fatalf("invalid -desc-column-width %g: want a fraction between 0 and 1", opts.DescColumnWidth)
+	}
+
+	if opts.QRMode == qrModeSCMDocs {
+		opts.QRMode = qrModeDocs
+	}
+	if opts.QRMode != qrModeCommand && opts.QRMode != qrModeDocs {
+		log.Fatalf("invalid -qr-mode %q: want %s, %s or %s", opts.QRMode, qrModeCommand, qrModeDocs, qrModeSCMDocs)
+	}
+
+	if _, ok := qrEncodings[opts.QREncoding]; !ok {
+		log.Fatalf("invalid -qr-encoding %q: want auto, byte, alphanumeric or numeric", opts.QREncoding)
+	}
+	encodeOptions, err := parseEncodeOptions(*encodeOpts)
+	if err != nil {
+		log.Fatalf("invalid -encode-opts:\n%v", err)
+	}
+	opts.EncodeOptions = encodeOptions
+
+	switch {
+	case *allQR && *allCode128:
+		log.Fatalf("-all-qr and -all-code128 cannot be combined")
+	case *allQR:
+		opts.Symbology = symbologyQR
+	case *allCode128:
+		opts.Symbology = symbologyCode128
+	}
+
+	level, ok := qrLevels[strings.ToUpper(*qrEC)]
+	if !ok {
+		log.Fatalf("invalid -qr-ec %q: want L, M, Q or H", *qrEC)
+	}
+	opts.QRLevel = level
+	if *qrLogo != "" {
+		logo, err := loadLogo(*qrLogo)
+		if err != nil {
+			log.Fatalf("invalid -qr-logo: %v", err)
+		}
+		opts.QRLogo = logo
+		switch {
+		case opts.QRLogoCells:
+			if flagSet("qr-ec") && level < qr.H {
+				log.Printf("warning: -qr-logo needs error correction H to stay scannable; using H instead of %s", strings.ToUpper(*qrEC))
 			}
-			scaled, err = barcode.Scale(raw, qrSize, qrSize)
+			opts.QRLevel = qr.H
+		case flagSet("qr-ec") && level < qr.H:
+			log.Printf("warning: -qr-logo needs error correction H to stay scannable; using H for the footer QR instead of %s", strings.ToUpper(*qrEC))
 		}
+	} else if opts.QRLogoCells {
+		log.Fatalf("-qr-logo-cells needs -qr-logo")
+	}
+
+	order, err := parseCellOrder(*cellOrder)
+	if err != nil {
+		log.Fatalf("invalid -cell-order: %v", err)
+	}
+	opts.CellOrder = order
+
+	if opts.FooterTextColor, err = parseHexColor(*footerTextColor); err != nil {
+		log.Fatalf("invalid -footer-text-color: %v", err)
+	}
+	if opts.CutGuideColor, err = parseHexColor(*cutGuideColor); err != nil {
+		log.Fatalf("invalid -cut-guide-color: %v", err)
+	}
+
+	if opts.PNGCompression, err = parsePNGCompression(*pngCompression); err != nil {
+		log.Fatalf("invalid -png-compression: %v", err)
+	}
+	if opts.Indexed && !strings.EqualFold(filepath.Ext(*out), ".png") {
+		log.Fatalf("-indexed applies to PNG output, not %s", *out)
+	}
 
+	if *templatesFile != "" {
+		if err := loadTemplates(*templatesFile); err != nil {
+			log.Fatalf("failed to load templates: %v", err)
+		}
+	}
+	if *templateName != "" {
+		t, err := lookupTemplate(*templateName)
 		if err != nil {
-			log.Printf("Barcode scale error for %q: %v", cmd.Code, err)
-			continue
+			log.Fatalf("invalid -template: %v", err)
 		}
+		if *nup > 1 {
+			log.Fatalf("-template and -nup cannot be combined")
+		}
+		opts.Template = &t
+		opts.PageWidthIn, opts.PageHeightIn = t.PageWidthMM/inch, t.PageHeightMM/inch
+	}
+	if *verifyPhotoFile != "" && *nup > 1 {
+		log.Fatalf("-verify-photo and -nup cannot be combined")
+	}
 
-		// 2. Barcode (common drawing logic)
-		bx := cx - float64(scaled.Bounds().Dx())/2
-		by := labelY + 35 // Position barcode below label
-		dc.DrawImage(scaled, int(bx), int(by))
+	set, err := lookupSet(*setName)
+	if err != nil {
+		log.Fatalf("invalid -set: %v", err)
+	}
 
-		// 3. Description (common drawing logic)
-		descY := by + float64(scaled.Bounds().Dy()) + 15
-		dc.SetFontFace(mustGoRegularFace(22)) // Increased description font size
-		dc.DrawStringWrapped(cmd.Description, x+8, descY, 0, 0, cellWidth-16, 1.4, gg.AlignCenter)
+	if *listBuiltin {
+		if err := printCommandTree(os.Stdout, set.Commands); err != nil {
+			log.Fatalf("failed to list commands: %v", err)
+		}
+		return
 	}
 
-	// --- Footer: repo QR + text --- (kept inside the page)
-	footerText := "https://github.com/arran4/git-barcode-sheet"
+	if *decodeIndexFiles != "" {
+		data, err := decodeIndex(strings.Split(*decodeIndexFiles, ","))
+		if err != nil {
+			log.Fatalf("failed to decode index: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			log.Fatalf("failed to decode index: %v", err)
+		}
+		buf.WriteByte('\n')
+		if _, err := buf.WriteTo(os.Stdout); err != nil {
+			log.Fatalf("failed to write index: %v", err)
+		}
+		return
+	}
 
-	footerRaw, err := qr.Encode(footerText, qr.M, qr.Auto)
-	if err != nil {
-		log.Printf("QR encode error for footer: %v", err)
-	} else {
-		// Keep the QR comfortably inside the bottom margin
-		footerSize := int(math.Min(float64(width)*0.16, margin*0.9))
+	if *footerOnly != "" {
+		if err := saveFooterQR(*footerOnly, opts.FooterURL, *footerOnlySize, opts); err != nil {
+			log.Fatalf("failed to write footer QR: %v", err)
+		}
+		fmt.Println("Saved:", *footerOnly)
+		return
+	}
 
-		footerScaled, err := barcode.Scale(footerRaw, footerSize, footerSize)
+	cmds := set.Commands
+	source := "set " + set.Name
+	if opts.Title == "" {
+		opts.Title = set.Title
+	}
+	if *commandsFile != "" {
+		if cmds, err = loadCommands(*commandsFile, !*noTrim); err != nil {
+			log.Fatalf("failed to load commands: %v", err)
+		}
+		source = "commands " + *commandsFile
+		if !flagSet("set") && !flagSet("title") {
+			opts.Title = defaultTitle
+		}
+		var disabled int
+		if cmds, disabled = enabledCommands(cmds); disabled > 0 {
+			log.Printf("skipping %d disabled command(s)", disabled)
+		}
+		switch {
+		case len(cmds) == 0 && disabled > 0:
+			log.Fatalf("%s: every command is disabled", *commandsFile)
+		case len(cmds) == 0:
+			log.Fatalf("%s: no commands", *commandsFile)
+		}
+	}
+	if *fromHistory != "" {
+		if *commandsFile != "" {
+			log.Fatalf("-from-history and -commands cannot be combined")
+		}
+		hopts := historyOptions{Program: set.Name, Top: *historyTop, Strip: *historyStrip}
+		if cmds, err = loadHistory(*fromHistory, hopts); err != nil {
+			log.Fatalf("failed to load history: %v", err)
+		}
+		source = "history " + *fromHistory
+	}
+	cmds = expandPlaceholders(cmds, *remote)
+	if *descriptionsFile != "" {
+		descs, err := loadDescriptions(*descriptionsFile)
+		if err != nil {
+			log.Fatalf("failed to load descriptions: %v", err)
+		}
+		cmds = applyDescriptions(cmds, expandDescriptions(descs, *remote), *descriptionsFile)
+	}
+	if *shuffle {
+		if *seed == 0 {
+			*seed = time.Now().UnixNano()
+			log.Printf("shuffle seed: %d", *seed)
+		}
+		cmds = shuffleCommands(cmds, *seed)
+	}
+
+	if err := checkQREncoding(cmds, opts); err != nil {
+		log.Fatalf("invalid -qr-encoding or qr.encoding:\n%v", err)
+	}
+
+	if cmds, err = fitCommands(cmds, opts); err != nil {
+		log.Fatalf("-overflow-command %s: %v", opts.OverflowCommand, err)
+	}
+
+	if opts.Numbered {
+		cmds = numberCommands(cmds)
+	}
+
+	if opts.Symbology == symbologyQR {
+		warnDenseQRs(cmds, opts)
+	}
+	if opts.QRMode == qrModeDocs {
+		warnMissingDocs(cmds, opts)
+	}
+
+	if *printConfig {
+		if err := writeConfig(os.Stderr, opts, source, len(cmds)); err != nil {
+			log.Fatalf("failed to print config: %v", err)
+		}
+	}
+
+	if *exportDir != "" {
+		paths, err := exportCommands(*exportDir, *exportFormat, cmds, opts)
+		if err != nil {
+			log.Fatalf("failed to export commands: %v", err)
+		}
+		fmt.Printf("Exported %d commands to %s\n", len(paths), *exportDir)
+		return
+	}
+
+	if *runSelfTest {
+		if err := selfTest(cmds, opts); err != nil {
+			log.Fatalf("self-test failed:\n%v", err)
+		}
+		fmt.Printf("Self-test passed: %d commands rendered\n", len(cmds))
+		return
+	}
+
+	if *runQualityReport {
+		results, err := qualityReport(cmds, opts, qualityThresholds{MinModuleMM: *qualityMinModuleMM, MinModulePx: *qualityMinModulePx})
+		if err != nil {
+			log.Fatalf("failed to build quality report: %v", err)
+		}
+		failed, err := printQualityReport(os.Stdout, results)
+		if err != nil {
+			log.Fatalf("failed to print quality report: %v", err)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *verifyPhotoFile != "" {
+		results, err := verifyPhoto(strings.Split(*verifyPhotoFile, ","), cmds, opts)
+		if err != nil {
+			log.Fatalf("failed to verify photo: %v", err)
+		}
+		unreadable, err := printPhotoResults(os.Stdout, results)
 		if err != nil {
-			log.Printf("QR scale error for footer: %v", err)
+			log.Fatalf("failed to print results: %v", err)
+		}
+		if unreadable > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if isPDF(*out) {
+		if *nup > 1 {
+			log.Fatalf("-nup is not supported for PDF output")
+		}
+		if _, err := savePDF(*out, cmds, opts); err != nil {
+			log.Fatalf("failed to save PDF: %v", err)
+		}
+		fmt.Println("Saved:", *out)
+		return
+	}
+
+	if *nup > 1 {
+		opts = nupOptions(opts, *nup, flagSet("cols"))
+	}
+	pages := sheetPages(cmds, opts)
+	for i, page := range pages {
+		var img image.Image
+		if *nup > 1 {
+			img, err = renderNUp(page, opts, *nup)
 		} else {
-			// Place QR above bottom margin, centered horizontally
-			fbX := float64(width)/2 - float64(footerScaled.Bounds().Dx())/2
-			fbY := float64(height) - margin - float64(footerSize) + 4
-			dc.DrawImage(footerScaled, int(fbX), int(fbY))
+			var dc *gg.Context
+			dc, _, err = renderSheet(page, opts.onPage(i))
+			if dc != nil {
+				img = dc.Image()
+			}
+		}
+		if err != nil {
+			log.Fatalf("failed to render sheet: %v", err)
+		}
 
-			// Footer text just above page bottom
-			textY := float64(height) - 12
-			dc.SetColor(color.Black)
-			dc.SetFontFace(mustGoRegularFace(12))
-			dc.DrawStringAnchored(footerText, float64(width)/2, textY, 0.5, 0)
+		path := pageFileName(*out, i+1)
+		if err := saveImage(path, img, opts); err != nil {
+			log.Fatalf("failed to save image: %v", err)
 		}
+		fmt.Println("Saved:", path)
 	}
 
-	out := "git-barcode-sheet-a4.png"
-	if err := dc.SavePNG(out); err != nil {
-		log.Fatalf("failed to save PNG: %v", err)
+	next := len(pages) + 1 // page number of the next extra page
+	if opts.Appendix {
+		appendix, err := renderAppendix(cmds, opts)
+		if err != nil {
+			log.Fatalf("failed to render appendix: %v", err)
+		}
+		for _, page := range appendix {
+			path := pageFileName(*out, next)
+			next++
+			if err := saveImage(path, page.Image(), opts); err != nil {
+				log.Fatalf("failed to save image: %v", err)
+			}
+			fmt.Println("Saved:", path)
+		}
 	}
 
-	fmt.Println("Saved:", out)
+	if opts.Index {
+		index, err := renderIndex(cmds, opts)
+		if err != nil {
+			log.Fatalf("failed to render index: %v", err)
+		}
+		for _, page := range index {
+			path := pageFileName(*out, next)
+			next++
+			if err := saveImage(path, page.Image(), opts); err != nil {
+				log.Fatalf("failed to save image: %v", err)
+			}
+			fmt.Println("Saved:", path)
+		}
+	}
 }
 
-// mustGoRegularFace returns a Go Regular font.Face at the given size,
-// always using the embedded goregular TTF.
-func mustGoRegularFace(size float64) font.Face {
-	if face, ok := fontCache[size]; ok {
-		return face
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// remotePlaceholder is substituted with the configured remote name.
+const remotePlaceholder = "{{remote}}"
+
+// expandPlaceholders returns a copy of cmds with {{remote}} replaced by remote
+// in the code, label and description.
+func expandPlaceholders(cmds []GitCmd, remote string) []GitCmd {
+	r := strings.NewReplacer(remotePlaceholder, remote)
+	out := make([]GitCmd, len(cmds))
+	for i, cmd := range cmds {
+		cmd.Code = r.Replace(cmd.Code)
+		cmd.Label = r.Replace(cmd.Label)
+		cmd.Description = r.Replace(cmd.Description)
+		out[i] = cmd
 	}
+	return out
+}
 
-	fnt, err := opentype.Parse(goregular.TTF)
-	if err != nil {
-		log.Fatalf("failed to parse goregular TTF: %v", err)
+// expandDescriptions returns a copy of descs with {{remote}} replaced by remote
+// in the codes and text, so an entry matches a command written either way.
+// Where both forms of a code are listed, the one spelled out wins.
+func expandDescriptions(descs map[string]descriptionEntry, remote string) map[string]descriptionEntry {
+	r := strings.NewReplacer(remotePlaceholder, remote)
+	out := make(map[string]descriptionEntry, len(descs))
+	for code, d := range descs {
+		if _, ok := descs[r.Replace(code)]; ok && strings.Contains(code, remotePlaceholder) {
+			continue
+		}
+		d.Description = r.Replace(d.Description)
+		d.Label = r.Replace(d.Label)
+		out[r.Replace(code)] = d
 	}
+	return out
+}
+
+// shuffleCommands returns a copy of cmds in a random order determined by seed.
+func shuffleCommands(cmds []GitCmd, seed int64) []GitCmd {
+	out := append([]GitCmd(nil), cmds...)
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	return out
+}
+
+// printedConfig is the JSON written by -print-config. The colours are
+// shadowed by their hex form. A field that may ever hold a secret, such as a
+// future server token, must be tagged `json:"-"` on Options.
+type printedConfig struct {
+	Options
+	FooterTextColor string
+	CutGuideColor   string
+	Source          string // where the commands came from, e.g. "set git"
+	Commands        int    // number of commands after filtering and expansion
+}
 
-	face, err := opentype.NewFace(fnt, &opentype.FaceOptions{
-		Size:    size,
-		DPI:     72,
-		Hinting: font.HintingFull,
+// writeConfig writes opts and the resolved command source and count as
+// indented JSON.
+func writeConfig(w io.Writer, opts Options, source string, commands int) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(printedConfig{
+		Options:         opts,
+		FooterTextColor: hexColor(opts.FooterTextColor),
+		CutGuideColor:   hexColor(opts.CutGuideColor),
+		Source:          source,
+		Commands:        commands,
 	})
-	if err != nil {
-		log.Fatalf("failed to create goregular face (size=%.1f): %v", size, err)
+}
+
+// hexColor formats c as #rrggbb, or "" for nil.
+func hexColor(c color.Color) string {
+	if c == nil {
+		return ""
 	}
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
 
-	fontCache[size] = face
-	return face
+// printCommandTree writes cmds as a tree grouped by category, one command per leaf.
+func printCommandTree(w io.Writer, cmds []GitCmd) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, cmd := range cmds {
+		if i == 0 || cmds[i-1].Category != cmd.Category {
+			category := cmd.Category
+			if category == "" {
+				category = "(uncategorised)"
+			}
+			if i > 0 {
+				fmt.Fprintln(tw)
+			}
+			fmt.Fprintln(tw, category)
+		}
+		branch := "├──"
+		if i == len(cmds)-1 || cmds[i+1].Category != cmd.Category {
+			branch = "└──"
+		}
+		fmt.Fprintf(tw, "%s %s\t%s\n", branch, cmd.Code, cmd.Description)
+	}
+	return tw.Flush()
 }
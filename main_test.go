@@ -0,0 +1,94 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/boombuler/barcode/qr"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// testOptions returns the options a run with no flags renders the default
+// set with.
+func testOptions() Options {
+	return Options{
+		DPI:              300,
+		Cols:             4,
+		Code128Width:     defaultCode128Width,
+		FontUnits:        fontUnitsPx,
+		Layout:           layoutGrid,
+		FillOrder:        fillLTR,
+		EmptyLabel:       emptyLabelCode,
+		MinFontSize:      7,
+		CropMarkOffsetMM: 1,
+		CropMarkLengthMM: 3,
+		CutGuideWidth:    1,
+		CutGuideColor:    color.RGBA{0x80, 0x80, 0x80, 0xff},
+		FooterTextColor:  color.RGBA{0, 0, 0, 0xff},
+		MaxPixels:        defaultMaxPixels,
+		DescColumnWidth:  0.5,
+		NumberCorner:     cornerTopRight,
+		TitleOverflow:    titleOverflowShrink,
+		OverflowPolicy:   overflowWarn,
+		OverflowCommand:  overflowCommandSkip,
+		QRMode:           qrModeCommand,
+		QREncoding:       "auto",
+		QRLevel:          qr.M,
+		FooterURL:        defaultFooterURL,
+		CellOrder:        defaultCellOrder,
+		PNGCompression:   pngCompressionLevels["default"],
+	}
+}
+
+// builtinCommands returns the default set as a run with no flags draws it.
+func builtinCommands(t *testing.T) []GitCmd {
+	t.Helper()
+	set, err := lookupSet(defaultSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return expandPlaceholders(set.Commands, "origin")
+}
+
+// quietLog drops log output, such as layout warnings, until the test ends.
+func quietLog(t *testing.T) {
+	t.Helper()
+	w := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(w) })
+}
+
+// decodeQR reads the QR drawn in box on img, with a tenth of its width of
+// page around it for the quiet zone.
+func decodeQR(img image.Image, box rect) (*gozxing.Result, error) {
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return nil, err
+	}
+	pad := box.W / 10
+	r := image.Rect(int(box.X-pad), int(box.Y-pad), int(box.X+box.W+pad), int(box.Y+box.H+pad)).Intersect(img.Bounds())
+	crop, err := bmp.Crop(r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+	if err != nil {
+		return nil, err
+	}
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	return qrcode.NewQRCodeReader().Decode(crop, hints)
+}
+
+func TestBuiltinSetsRender(t *testing.T) {
+	quietLog(t)
+	for _, set := range CommandSets {
+		t.Run(set.Name, func(t *testing.T) {
+			opts := testOptions()
+			opts.Title = set.Title
+			cmds := expandPlaceholders(set.Commands, "origin")
+			if err := selfTest(cmds, opts); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image"
+	"io"
+	"testing"
+)
+
+// matrixCommands is the tiny set the matrix renders: one command per
+// symbology path (Code128, QR, multi-line QR, byte-mode payload).
+var matrixCommands = []GitCmd{
+	{Code: "git status", Description: "Show working tree status."},
+	{Code: "git log --oneline --graph --decorate --all", Label: "Pretty log", Description: "Compact decorated log graph."},
+	{Code: "git fetch\ngit status -sb", Label: "fetch\nstatus", Description: "Fetch then show status."},
+	{Code: base64.StdEncoding.EncodeToString([]byte{0, 'g', 'i', 't', 0xff}), Label: "payload", Base64: true},
+}
+
+// matrixPages are the page sizes the matrix renders, in inches.
+var matrixPages = []struct {
+	name string
+	w, h float64
+}{
+	{"A4", a4WidthInches, a4HeightInches},
+	{"A4 landscape", a4HeightInches, a4WidthInches},
+	{"A5", 5.83, 8.27},
+	{"A6", 4.13, 5.83},
+	{"Letter", 8.5, 11},
+}
+
+func TestRenderMatrix(t *testing.T) {
+	quietLog(t)
+	for _, page := range matrixPages {
+		for _, dpi := range []float64{150, 300, 600} {
+			for _, layout := range []string{layoutGrid, layoutList} {
+				t.Run(fmt.Sprintf("%s/%gdpi/%s", page.name, dpi, layout), func(t *testing.T) {
+					opts := testOptions()
+					opts.PageWidthIn, opts.PageHeightIn = page.w, page.h
+					opts.DPI, opts.Cols = dpi, 2
+					opts.Layout, opts.HeaderRow = layout, layout == layoutList
+					renderBoth(t, matrixCommands, opts)
+				})
+			}
+		}
+	}
+}
+
+func TestRenderMatrixTemplates(t *testing.T) {
+	quietLog(t)
+	for _, tmpl := range labelTemplates {
+		t.Run(tmpl.Name, func(t *testing.T) {
+			tmpl := tmpl
+			opts := testOptions()
+			opts.Template = &tmpl
+			opts.PageWidthIn, opts.PageHeightIn = tmpl.PageWidthMM/inch, tmpl.PageHeightMM/inch
+			renderBoth(t, matrixCommands, opts)
+		})
+	}
+}
+
+func TestRenderMatrixSymbology(t *testing.T) {
+	quietLog(t)
+	for _, symbology := range []string{symbologyQR, symbologyCode128} {
+		t.Run(symbology, func(t *testing.T) {
+			opts := testOptions()
+			opts.Symbology, opts.Cols = symbology, 1
+			renderBoth(t, matrixCommands, opts)
+		})
+	}
+}
+
+func TestDrawCellHook(t *testing.T) {
+	quietLog(t)
+	opts := testOptions()
+	opts.Cols = 2
+	calls := 0
+	opts.DrawCell = func(dc canvas, cmd GitCmd, cell rect, opts Options) (map[string]rect, error) {
+		calls++
+		return drawCell(dc, cmd, cell.X, cell.Y, cell.W, cell.H, opts)
+	}
+	renderBoth(t, matrixCommands, opts)
+	if want := 2 * len(matrixCommands); calls != want {
+		t.Errorf("DrawCell hook called %d times, want %d", calls, want)
+	}
+}
+
+// renderBoth renders cmds with opts on the raster and then the PDF canvas, and
+// fails the test if either errors, skips or misplaces a command, or the raster
+// page is empty.
+func renderBoth(t *testing.T, cmds []GitCmd, opts Options) {
+	t.Helper()
+	dc, report, err := renderSheet(cmds, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkReport(report, len(cmds)); err != nil {
+		t.Error(err)
+	}
+	if !hasInk(dc.Image()) {
+		t.Error("rendered an empty page")
+	}
+
+	width, height := opts.pagePx()
+	c := newPDFCanvas(width, height, opts.DPI)
+	report, err = drawSheet(c, cmds, opts)
+	if err != nil {
+		t.Fatalf("pdf: %v", err)
+	}
+	if err := checkReport(report, len(cmds)); err != nil {
+		t.Errorf("pdf: %v", err)
+	}
+	if err := c.pdf.Output(io.Discard); err != nil {
+		t.Errorf("pdf: %v", err)
+	}
+}
+
+// hasInk reports whether img has any dark pixel.
+func hasInk(img image.Image) bool {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if isDark(img.At(x, y)) {
+				return true
+			}
+		}
+	}
+	return false
+}
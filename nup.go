@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"math"
+
+	"github.com/boombuler/barcode/qr"
+	"github.com/fogleman/gg"
+)
+
+// nupGrid picks the columns x rows split of the page into n cards whose aspect
+// ratio is closest to the page's own, so each card keeps the sheet's proportions.
+func nupGrid(n int, pageWidth, pageHeight float64) (cols, rows int) {
+	best := math.Inf(1)
+	pageAspect := pageWidth / pageHeight
+	for c := 1; c <= n; c++ {
+		if n%c != 0 {
+			continue
+		}
+		r := n / c
+		aspect := (pageWidth / float64(c)) / (pageHeight / float64(r))
+		if d := math.Abs(math.Log(aspect / pageAspect)); d < best {
+			best, cols, rows = d, c, r
+		}
+	}
+	return cols, rows
+}
+
+// nupCardOptions returns opts for one of n cards tiled over the page: the
+// card's page size, with the sheet scaled down to match.
+func nupCardOptions(opts Options, n int) Options {
+	width, height := opts.pagePx()
+	cols, rows := nupGrid(n, float64(width), float64(height))
+	cardWidth, cardHeight := width/cols, height/rows
+
+	card := opts
+	card.PageWidthIn = float64(cardWidth) / opts.DPI
+	card.PageHeightIn = float64(cardHeight) / opts.DPI
+	card.Scale = opts.px(math.Sqrt(float64(cardWidth*cardHeight) / float64(width*height)))
+	return card
+}
+
+// nupOptions adapts the sheet options to n cards, which are a fraction of the
+// page the -cols default and the footer QR were sized for. Columns are chosen
+// from the card's physical size unless -cols was given, and the footer QR is
+// left off, with one warning, when it would be under a pixel per module.
+func nupOptions(opts Options, n int, colsSet bool) Options {
+	if !colsSet {
+		opts.Cols = 0
+	}
+	if opts.NoFooter {
+		return opts
+	}
+	card := nupCardOptions(opts, n)
+	width, _ := card.pagePx()
+	raw, err := qr.Encode(opts.FooterURL, opts.footerQRLevel(), qr.Auto)
+	if err != nil || raw.Bounds().Dx() > footerQRSize(width, card) {
+		log.Printf("warning: the footer QR is too small to draw on %d cards; leaving it off", n)
+		opts.NoFooter = true
+	}
+	return opts
+}
+
+// renderNUp renders the sheet n times at card size and tiles the cards over
+// one page, with dashed cut lines between them.
+func renderNUp(cmds []GitCmd, opts Options, n int) (image.Image, error) {
+	if err := opts.checkPixels(); err != nil {
+		return nil, err
+	}
+	width, height := opts.pagePx()
+	cols, rows := nupGrid(n, float64(width), float64(height))
+	cardWidth := width / cols
+	cardHeight := height / rows
+	if cardWidth < 1 || cardHeight < 1 {
+		return nil, fmt.Errorf("page too small for %d cards", n)
+	}
+
+	card, _, err := renderSheet(cmds, nupCardOptions(opts, n))
+	if err != nil {
+		return nil, err
+	}
+
+	dc := gg.NewContext(width, height)
+	dc.SetRGB(1, 1, 1)
+	dc.Clear()
+
+	page := dc.Image().(*image.RGBA)
+	for i := 0; i < n; i++ {
+		x := (i % cols) * cardWidth
+		y := (i / cols) * cardHeight
+		r := image.Rect(x, y, x+cardWidth, y+cardHeight)
+		draw.Draw(page, r, card.Image(), image.Point{}, draw.Src)
+	}
+
+	// Cut lines between cards.
+	dc.SetColor(color.RGBA{R: 150, G: 150, B: 150, A: 255})
+	dc.SetLineWidth(opts.px(1.5))
+	dc.SetDash(opts.px(12), opts.px(8))
+	for c := 1; c < cols; c++ {
+		x := float64(c * cardWidth)
+		dc.DrawLine(x, 0, x, float64(height))
+		dc.Stroke()
+	}
+	for r := 1; r < rows; r++ {
+		y := float64(r * cardHeight)
+		dc.DrawLine(0, y, float64(width), y)
+		dc.Stroke()
+	}
+
+	return dc.Image(), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestNUpCards(t *testing.T) {
+	tests := []struct {
+		n          int
+		wantFooter bool
+	}{
+		{2, true},
+		{4, false},
+		{8, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d cards", tt.n), func(t *testing.T) {
+			var logs bytes.Buffer
+			w := log.Writer()
+			log.SetOutput(&logs)
+			t.Cleanup(func() { log.SetOutput(w) })
+
+			opts := nupOptions(testOptions(), tt.n, false)
+			if opts.NoFooter == tt.wantFooter {
+				t.Errorf("footer QR on %d cards: %v, want %v", tt.n, !opts.NoFooter, tt.wantFooter)
+			}
+			if want := map[bool]int{true: 0, false: 1}[tt.wantFooter]; strings.Count(logs.String(), "footer QR") != want {
+				t.Errorf("want %d footer warning(s), logged:\n%s", want, logs.String())
+			}
+			cmds := builtinCommands(t)
+			_, report, err := renderSheet(cmds, nupCardOptions(opts, tt.n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := checkReport(report, len(cmds)); err != nil {
+				t.Error(err)
+			}
+			for _, cell := range report.Cells {
+				if _, ok := cell.Elements[cellBarcode]; !ok {
+					t.Errorf("%q has no barcode on the card", cell.Cmd.Code)
+				}
+			}
+			if strings.Contains(logs.String(), "error") {
+				t.Errorf("card render logged errors:\n%s", logs.String())
+			}
+		})
+	}
+}
+
+func TestNUpKeepsCols(t *testing.T) {
+	quietLog(t)
+	opts := testOptions()
+	opts.Cols = 2
+	if got := nupOptions(opts, 4, true).Cols; got != 2 {
+		t.Errorf("-cols 2 -nup 4 drew %d columns", got)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+// A4 page size in inches.
+const (
+	a4WidthInches  = 8.27
+	a4HeightInches = 11.69
+)
+
+// Threshold (characters) for "short" vs "long" commands
+const shortCmdMaxLen = 26
+
+// maxAutoCols bounds the search when the column count is picked automatically.
+const maxAutoCols = 8
+
+// Options controls how the sheet is laid out.
+type Options struct {
+	DPI         float64  // output resolution
+	Cols        int      // grid columns; 0 picks the densest grid meeting the module constraints
+	BarHeightMM float64  // physical Code128 bar height; 0 uses a fraction of the cell height
+	MinModuleMM float64  // minimum physical module (narrow bar / QR dot) width; 0 disables the check
+	MaxModulePx int      // cap on the module width in pixels so sparse sheets don't balloon; 0 disables
+	FooterURL   string   // text encoded in the footer QR and printed under it
+	QRMode      string   // what QR cells encode: "command" (default) or "docs" for the documentation URL
+	QREncoding  string   // QR cell encoding mode, a key of qrEncodings; "" is auto
+	Symbology   string   // "qr" or "code128" to draw every command that way; "" picks by length
+	CellOrder   []string // vertical order of cell elements; nil means label, barcode, desc
+	Title       string   // page title; empty uses the built-in title
+	// QRLevel is the QR error correction for cells and the footer.
+	QRLevel qr.ErrorCorrectionLevel
+	// EncodeOptions are encoder settings keyed by encodeOptionKeys, e.g.
+	// "code128.checksum": "false", that a command's own EncodeOptions
+	// override key by key.
+	EncodeOptions map[string]string
+	// QRLogo is drawn over the centre of the footer QR, and of the cell QRs
+	// too with QRLogoCells.
+	QRLogo      image.Image `json:"-"`
+	QRLogoCells bool
+	// Code128Width is the width Code128s are scaled to: up to 1 a fraction of
+	// the cell width, above 1 pixels (at most the cell width).
+	Code128Width float64
+	// Code128Natural draws Code128s at code128NaturalModuleMM bars instead of
+	// stretching them to Code128Width, so short codes stay narrow.
+	Code128Natural bool
+	// TitleOverflow is what happens when the title is wider than the page:
+	// "shrink" the font, "wrap" onto two lines, or "clip" (draw as is).
+	TitleOverflow string
+
+	// OverflowPolicy is what happens when a cell's label, barcode and
+	// description are taller than the cell: "shrink" the text, "warn" (default)
+	// or "error" out.
+	OverflowPolicy string
+	// OverflowCommand is what happens to a command too long for a QR code:
+	// "skip" it with a warning (default), "truncate" it to what fits with a
+	// marked label, or "error" out.
+	OverflowCommand string
+
+	// FillOrder is the direction grid and label rows fill in: "ltr"
+	// (default) or "rtl", right to left.
+	FillOrder string
+
+	// Layout is "grid" (default) or "list", one command per row with the
+	// barcode, label and description side by side.
+	Layout    string
+	HeaderRow bool // list layout: bold column titles above the first row
+
+	NoText   bool // barcode-only cells: no label or description
+	NoDesc   bool // label and barcode only; the description's space goes to the barcode
+	Practice bool // draw an empty checkbox in each cell's corner for ticking off practised commands
+
+	// Page size in inches; zero means A4 portrait.
+	PageWidthIn, PageHeightIn float64
+	// Template lays commands out on a label sheet instead of the grid, a
+	// sheet's worth of labels per page; the page size must match it.
+	Template *labelTemplate
+	// FontUnits is how font sizes are read: "px" (default) draws them as pixels,
+	// "pt" as points on paper, converted with DPI so text keeps its printed size.
+	FontUnits string
+	// Font sizes in FontUnits; zero keeps the built-in size.
+	TitleSize, LabelSize, DescSize float64
+	FooterTextSize                 float64
+	FooterTextColor                color.Color // nil is black
+	// MinFontSize is the floor in printed points that auto-shrinking text
+	// stops at; text still too big there follows OverflowPolicy.
+	MinFontSize float64
+	// EmptyLabel is what labels an entry without a Label: "code" (default),
+	// "none" or "subcommand" for the code's first two words.
+	EmptyLabel string
+	// LabelMaxWidth caps the label width: up to 1 a fraction of the cell
+	// width, above 1 pixels; zero is the cell width less padding. Labels
+	// wider than that shrink towards MinFontSize, then end in an ellipsis.
+	LabelMaxWidth float64
+	// AutoFont sizes labels and descriptions without an explicit size as a
+	// fraction of the cell height, between MinFontSize and autoFontMaxPt.
+	AutoFont bool
+
+	// CutGuides draws edge-to-edge lines at the grid's cell boundaries in
+	// CutGuideColor, CutGuideWidth pixels wide.
+	CutGuides     bool
+	CutGuideColor color.Color
+	CutGuideWidth float64
+	// CropMarks draws trim marks at the grid's corners, out in the margin:
+	// CropMarkLengthMM long, starting CropMarkOffsetMM clear of the corner.
+	CropMarks        bool
+	CropMarkOffsetMM float64
+	CropMarkLengthMM float64
+
+	// Appendix adds pages listing every command's exact code in monospace,
+	// for typing without a scanner.
+	Appendix bool
+	// Index adds pages of QR codes that together encode the command list as
+	// JSON, so the file can be recovered from the print with -decode-index.
+	Index bool
+	// DescColumn moves descriptions out of the cell stack into a column
+	// DescColumnWidth of the cell (or list row) wide, right of the barcode.
+	DescColumn      bool
+	DescColumnWidth float64
+	// Numbered draws each command's position on the sheet, counting on across
+	// pages, in NumberCorner of its cell at NumberSize (in FontUnits; zero is
+	// built in). The number is not part of the barcode.
+	Numbered     bool
+	NumberCorner string
+	NumberSize   float64
+	// RepeatFooterAsHeader draws the footer QR and URL as a compact strip at
+	// the top of every page after the first.
+	RepeatFooterAsHeader bool
+	// NoFooter leaves off the footer QR and URL and grows the grid down into
+	// the bottom margin they took.
+	NoFooter bool
+	// SymbologyLegend explains, in the bottom margin, what the stripes and
+	// squares on the sheet are scanned with.
+	SymbologyLegend bool
+
+	Snap bool // place barcodes on whole page pixels
+
+	Balance bool // give barcodes a shared module width so the grid looks even
+	// MaxPixels caps width*height of a raster page so a huge -dpi can't
+	// exhaust memory; zero disables the check.
+	MaxPixels int64
+
+	// RotatePage turns raster pages clockwise by 90, 180 or 270 degrees just
+	// before they are saved, for printers that feed the media turned.
+	RotatePage int
+
+	// PNGCompression is the zlib level used for PNG output.
+	PNGCompression png.CompressionLevel
+	// Indexed saves PNG pages with indexedPalette instead of full colour.
+	Indexed bool
+	// Scale multiplies the fixed pixel sizes (fonts, margins, gaps), e.g. to
+	// shrink a full sheet onto an N-up card; zero means 1.
+	Scale float64
+
+	// DrawCell, when set, draws each grid and template cell in place of
+	// drawCell. dc is the page canvas, raster or PDF, in page pixels with the
+	// origin at the top-left; cell is the cell's box on it. Call drawCell from
+	// the hook to keep the default content and draw over it, or encodeCell for
+	// just the scaled barcode. The returned boxes go into the render report, so
+	// -self-test checks them like the default ones.
+	DrawCell func(dc canvas, cmd GitCmd, cell rect, opts Options) (map[string]rect, error) `json:"-"`
+
+	// balance holds the shared sizes worked out for the sheet under Balance.
+	balance balancedSizes
+	// page is the 0-based sheet page being drawn, see onPage.
+	page int
+}
+
+// pagePx returns the page size in pixels.
+func (o Options) pagePx() (width, height int) {
+	w, h := o.PageWidthIn, o.PageHeightIn
+	if w <= 0 || h <= 0 {
+		w, h = a4WidthInches, a4HeightInches
+	}
+	return int(w * o.DPI), int(h * o.DPI)
+}
+
+// defaultMaxPixels allows an A3 page at 600 DPI (about 70 million pixels).
+const defaultMaxPixels = 100_000_000
+
+// checkPixels fails before a raster page is allocated if it would exceed
+// o.MaxPixels.
+func (o Options) checkPixels() error {
+	width, height := o.pagePx()
+	if o.MaxPixels > 0 && int64(width)*int64(height) > o.MaxPixels {
+		return fmt.Errorf("page is %dx%d = %d pixels, over the -max-pixels limit of %d; lower -dpi or use a smaller page",
+			width, height, int64(width)*int64(height), o.MaxPixels)
+	}
+	return nil
+}
+
+// Font size units accepted by Options.FontUnits.
+const (
+	fontUnitsPx = "px"
+	fontUnitsPt = "pt"
+)
+
+// referenceDPI is the resolution the built-in pixel font sizes were designed at.
+const referenceDPI = 300
+
+// fontSize returns size, or when it is unset the built-in size def (pixels at
+// referenceDPI) in o.FontUnits, so "pt" keeps the default sheet's look at any DPI.
+func (o Options) fontSize(size, def float64) float64 {
+	if size > 0 {
+		return size
+	}
+	if o.FontUnits == fontUnitsPt {
+		return def * 72 / referenceDPI
+	}
+	return def
+}
+
+// font returns the Go Regular font for a size in o.FontUnits, honouring o.Scale.
+func (o Options) font(size float64) fontSpec {
+	dpi := 72.0
+	if o.FontUnits == fontUnitsPt {
+		dpi = o.DPI
+	}
+	return fontSpec{size: o.px(size), dpi: dpi}
+}
+
+// aboveFontFloor reports whether a font size in o.FontUnits is at least
+// o.MinFontSize points on paper, the smallest size auto-shrinking may reach.
+func (o Options) aboveFontFloor(size float64) bool {
+	return o.font(size).pixelSize() >= o.MinFontSize*o.DPI/72
+}
+
+// Label and description sizes under AutoFont, as fractions of the cell
+// height; at the default grid they come out near the built-in sizes.
+const (
+	autoLabelFraction = 0.08
+	autoDescFraction  = 0.07
+	autoFontMaxPt     = 24
+)
+
+// cellFontSizes returns the label and description sizes, in o.FontUnits, for
+// a cell cellHeight pixels tall.
+func (o Options) cellFontSizes(cellHeight float64) (label, desc float64) {
+	label, desc = o.fontSize(o.LabelSize, 24), o.fontSize(o.DescSize, 22)
+	if !o.AutoFont {
+		return label, desc
+	}
+	// Pixels per font unit, so the clamps in points convert to o.FontUnits.
+	perUnit := o.font(1).pixelSize()
+	auto := func(fraction float64) float64 {
+		px := math.Max(math.Min(cellHeight*fraction, autoFontMaxPt*o.DPI/72), o.MinFontSize*o.DPI/72)
+		return px / perUnit
+	}
+	if o.LabelSize <= 0 {
+		label = auto(autoLabelFraction)
+	}
+	if o.DescSize <= 0 {
+		desc = auto(autoDescFraction)
+	}
+	return label, desc
+}
+
+// fontFloor is MinFontSize in o.FontUnits.
+func (o Options) fontFloor() float64 {
+	return o.MinFontSize * o.DPI / 72 / o.font(1).pixelSize()
+}
+
+// labelMaxWidth is the widest a label may be drawn in a cell cellWidth wide
+// with pad either side; LabelMaxWidth narrows it like GitCmd.DescWidth.
+func (o Options) labelMaxWidth(cellWidth, pad float64) float64 {
+	w := cellWidth - 2*pad
+	switch {
+	case o.LabelMaxWidth > 1:
+		return math.Min(w, o.px(o.LabelMaxWidth))
+	case o.LabelMaxWidth > 0:
+		return math.Min(w, cellWidth*o.LabelMaxWidth)
+	}
+	return w
+}
+
+// code128Width is the width a Code128 is scaled to in a cell cellWidth wide.
+func (o Options) code128Width(cellWidth float64) float64 {
+	switch {
+	case o.Code128Width > 1:
+		return math.Min(cellWidth, o.px(o.Code128Width))
+	case o.Code128Width > 0:
+		return cellWidth * o.Code128Width
+	}
+	return cellWidth * defaultCode128Width
+}
+
+// fitLabelSize shrinks a label size until its widest line fits maxWidth,
+// but not below fontFloor; ellipsizeLines cuts what still doesn't fit.
+func (o Options) fitLabelSize(c canvas, lines []string, size, maxWidth float64) float64 {
+	c.SetFont(o.font(size))
+	w, _ := measureLines(c, lines, labelSpacing)
+	if w <= maxWidth {
+		return size
+	}
+	return math.Min(size, math.Max(size*maxWidth/w, o.fontFloor()))
+}
+
+// boldFont is font in Go Bold.
+func (o Options) boldFont(size float64) fontSpec {
+	f := o.font(size)
+	f.bold = true
+	return f
+}
+
+// monoFont is font in Go Mono.
+func (o Options) monoFont(size float64) fontSpec {
+	f := o.font(size)
+	f.mono = true
+	return f
+}
+
+// snap rounds a barcode coordinate to a whole page pixel when o.Snap is set.
+// Modules are already whole pixels wide, so every bar edge then falls on the
+// pixel grid, in the PDF as well as the raster output.
+func (o Options) snap(v float64) float64 {
+	if o.Snap {
+		return math.Round(v)
+	}
+	return v
+}
+
+// px scales a fixed pixel size by o.Scale.
+func (o Options) px(v float64) float64 {
+	if o.Scale <= 0 {
+		return v
+	}
+	return v * o.Scale
+}
+
+// mmToPx converts millimetres to pixels at the given DPI.
+func mmToPx(mm, dpi float64) float64 {
+	return mm / 25.4 * dpi
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/boombuler/barcode/qr"
+	"github.com/fogleman/gg"
+	"golang.org/x/image/draw"
+)
+
+// PNG signature plus the IHDR chunk (length, type, 13 data bytes, CRC).
+const pngHeaderLen = 8 + 4 + 4 + 13 + 4
+
+// isPDF reports whether path names a PDF, which is rendered as vectors rather than saved from an image.
+func isPDF(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".pdf")
+}
+
+// pngCompressionLevels maps -png-compression names to encoder levels.
+var pngCompressionLevels = map[string]png.CompressionLevel{
+	"default":          png.DefaultCompression,
+	"best-speed":       png.BestSpeed,
+	"best-compression": png.BestCompression,
+	"no-compression":   png.NoCompression,
+}
+
+// parsePNGCompression returns the encoder level for a -png-compression name.
+func parsePNGCompression(name string) (png.CompressionLevel, error) {
+	level, ok := pngCompressionLevels[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown PNG compression %q (want default, best-speed, best-compression or no-compression)", name)
+	}
+	return level, nil
+}
+
+// saveImage writes img to path, choosing PNG or JPEG from the file extension,
+// turned by opts.RotatePage. The physical resolution is recorded so print
+// dialogs size the page correctly.
+func saveImage(path string, img image.Image, opts Options) error {
+	img = rotateImage(img, opts.RotatePage)
+	return writeFileAtomic(path, func(w io.Writer) error {
+		switch ext := strings.ToLower(filepath.Ext(path)); ext {
+		case ".png":
+			if opts.Indexed {
+				img = toPaletted(img, indexedPalette(opts))
+			}
+			return encodePNG(w, img, opts.DPI, opts.PNGCompression)
+		case ".jpg", ".jpeg":
+			return encodeJPEG(w, img, opts.DPI)
+		default:
+			return fmt.Errorf("unsupported output format %q (want .png, .jpg or .jpeg)", ext)
+		}
+	})
+}
+
+// indexedGrays are the greys of the -indexed palette: black, white, the
+// border and guide greys the sheet draws, and a ramp for antialiased edges.
+var indexedGrays = []uint8{0, 32, 64, 96, 128, 150, 160, 192, 200, 220, 224, 255}
+
+// indexedPalette is indexedGrays plus opts' theme colours, few enough for a
+// 4-bit PNG.
+func indexedPalette(opts Options) color.Palette {
+	var p color.Palette
+	for _, y := range indexedGrays {
+		p = append(p, color.Gray{Y: y})
+	}
+	for _, c := range []color.Color{opts.FooterTextColor, opts.CutGuideColor} {
+		if c == nil {
+			continue
+		}
+		r, g, b, _ := c.RGBA()
+		if pr, pg, pb, _ := p.Convert(c).RGBA(); pr != r || pg != g || pb != b {
+			p = append(p, c)
+		}
+	}
+	return p
+}
+
+// toPaletted maps every pixel of img to the nearest colour of p. It doesn't
+// dither, so barcode bars keep their hard black and white edges.
+func toPaletted(img image.Image, p color.Palette) *image.Paletted {
+	b := img.Bounds()
+	out := image.NewPaletted(b, p)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	return out
+}
+
+// rotateImage returns img turned clockwise by degrees, one of 0, 90, 180 or
+// 270; a quarter turn swaps the width and height.
+func rotateImage(img image.Image, degrees int) image.Image {
+	if degrees == 0 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	if degrees != 180 {
+		out = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			switch degrees {
+			case 90:
+				out.Set(h-1-y, x, c)
+			case 180:
+				out.Set(w-1-x, h-1-y, c)
+			case 270:
+				out.Set(y, w-1-x, c)
+			}
+		}
+	}
+	return out
+}
+
+// writeFileAtomic calls write with a buffered temporary file next to path and
+// renames it into place only once everything has been written, so a failed or
+// killed run never leaves a truncated file at path.
+func writeFileAtomic(path string, write func(w io.Writer) error) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	// CreateTemp makes the file private; outputs are meant to be shared.
+	if err := f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// encodePNG writes img as PNG with a pHYs chunk carrying dpi as pixels per metre.
+// image/png never emits pHYs, so the chunk is spliced in right after IHDR.
+func encodePNG(w io.Writer, img image.Image, dpi float64, level png.CompressionLevel) error {
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: level}
+	if err := enc.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	ppm := uint32(math.Round(dpi / 0.0254))
+	chunk := make([]byte, 4+4+9+4)
+	binary.BigEndian.PutUint32(chunk[0:], 9)
+	copy(chunk[4:], "pHYs")
+	binary.BigEndian.PutUint32(chunk[8:], ppm)
+	binary.BigEndian.PutUint32(chunk[12:], ppm)
+	chunk[16] = 1 // unit: metre
+	binary.BigEndian.PutUint32(chunk[17:], crc32.ChecksumIEEE(chunk[4:17]))
+
+	for _, part := range [][]byte{data[:pngHeaderLen], chunk, data[pngHeaderLen:]} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeJPEG writes img as JPEG with a JFIF APP0 segment carrying dpi as its density.
+// image/jpeg writes no APP0 at all, so one is inserted after the SOI marker.
+func encodeJPEG(w io.Writer, img image.Image, dpi float64) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	density := uint16(math.Min(math.Round(dpi), math.MaxUint16))
+	app0 := []byte{
+		0xFF, 0xE0, // APP0 marker
+		0x00, 0x10, // segment length
+		'J', 'F', 'I', 'F', 0x00,
+		0x01, 0x02, // version 1.02
+		0x01,       // units: dots per inch
+		0, 0, 0, 0, // X/Y density, filled below
+		0x00, 0x00, // no thumbnail
+	}
+	binary.BigEndian.PutUint16(app0[12:], density)
+	binary.BigEndian.PutUint16(app0[14:], density)
+
+	for _, part := range [][]byte{data[:2], app0, data[2:]} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// footerQRImage renders the footer QR size pixels square on a white
+// background, with opts.QRLogo over it when set. The symbol is drawn in whole
+// pixels per module inside a qrQuietModules border, so it still scans when
+// pasted onto a dark or busy background.
+func footerQRImage(text string, size int, opts Options) (image.Image, error) {
+	level := opts.footerQRLevel()
+	raw, err := qr.Encode(text, level, qr.Auto)
+	if err != nil {
+		return nil, fmt.Errorf("QR encode %q: %w", text, err)
+	}
+	modules := raw.Bounds().Dx()
+	if size < modules+2*qrQuietModules {
+		return nil, fmt.Errorf("%dpx is too small for a %d module QR and its quiet zone", size, modules)
+	}
+	symbol := size / (modules + 2*qrQuietModules) * modules
+	footerScaled, err := encodeQR(text, symbol, level)
+	if err != nil {
+		return nil, err
+	}
+
+	dc := gg.NewContext(size, size)
+	dc.SetRGB(1, 1, 1)
+	dc.Clear()
+	offset := (size - symbol) / 2
+	dc.DrawImage(footerScaled, offset, offset)
+	if opts.QRLogo != nil {
+		box := rect{float64(offset), float64(offset), float64(symbol), float64(symbol)}
+		drawQRLogo(&rasterCanvas{dc}, box, opts.QRLogo, level)
+	}
+	return dc.Image(), nil
+}
+
+// saveFooterQR writes the footer QR on its own to an image file. A .ico path
+// gets a multi-size favicon instead and ignores size.
+func saveFooterQR(out, text string, size int, opts Options) error {
+	if isICO(out) {
+		return saveFooterICO(out, text, opts)
+	}
+	img, err := footerQRImage(text, size, opts)
+	if err != nil {
+		return err
+	}
+	return saveImage(out, img, opts)
+}
+
+// Favicon sizes packed into a footer .ico, and the size the QR is rendered at
+// before being downsampled to them.
+var icoSizes = []int{16, 32, 48}
+
+const icoSourceSize = 480
+
+// isICO reports whether path names a Windows icon file.
+func isICO(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ico")
+}
+
+// saveFooterICO writes the footer QR as a 16, 32 and 48 pixel favicon. Most
+// URLs need more modules than 16 or 32 pixels, so the QR is drawn large and
+// smoothly downsampled; the small icons won't scan but still read as the
+// repo's QR in a browser tab.
+func saveFooterICO(out, text string, opts Options) error {
+	src, err := footerQRImage(text, icoSourceSize, opts)
+	if err != nil {
+		return err
+	}
+
+	var imgs []image.Image
+	for _, size := range icoSizes {
+		dst := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+		imgs = append(imgs, dst)
+	}
+
+	return writeFileAtomic(out, func(w io.Writer) error {
+		return encodeICO(w, imgs)
+	})
+}
+
+// encodeICO writes imgs (each at most 256x256) as an ICO file with
+// PNG-compressed entries, which every current browser and OS accepts.
+func encodeICO(w io.Writer, imgs []image.Image) error {
+	pngs := make([][]byte, len(imgs))
+	for i, img := range imgs {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+		pngs[i] = buf.Bytes()
+	}
+
+	// ICONDIR header, then one 16-byte ICONDIRENTRY per image.
+	header := make([]byte, 6+16*len(imgs))
+	binary.LittleEndian.PutUint16(header[2:], 1) // type: icon
+	binary.LittleEndian.PutUint16(header[4:], uint16(len(imgs)))
+	offset := len(header)
+	for i, img := range imgs {
+		e := header[6+16*i:]
+		b := img.Bounds()
+		e[0] = byte(b.Dx()) // 0 means 256
+		e[1] = byte(b.Dy())
+		binary.LittleEndian.PutUint16(e[4:], 1)  // colour planes
+		binary.LittleEndian.PutUint16(e[6:], 32) // bits per pixel
+		binary.LittleEndian.PutUint32(e[8:], uint32(len(pngs[i])))
+		binary.LittleEndian.PutUint32(e[12:], uint32(offset))
+		offset += len(pngs[i])
+	}
+
+	for _, part := range append([][]byte{header}, pngs...) {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+func TestIndexed(t *testing.T) {
+	quietLog(t)
+	opts := testOptions()
+	opts.Indexed = true
+	dc, report, err := renderSheet(sheetPages(builtinCommands(t), opts)[0], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := dc.Image()
+	pal := toPaletted(src, indexedPalette(opts))
+	var full, indexed bytes.Buffer
+	if err := encodePNG(&full, src, opts.DPI, opts.PNGCompression); err != nil {
+		t.Fatal(err)
+	}
+	if err := encodePNG(&indexed, pal, opts.DPI, opts.PNGCompression); err != nil {
+		t.Fatal(err)
+	}
+	if indexed.Len() >= full.Len() {
+		t.Errorf("-indexed PNG is %d bytes, not smaller than the %d byte full colour one", indexed.Len(), full.Len())
+	}
+	for _, cell := range report.Cells {
+		b, ok := cell.Elements[cellBarcode]
+		if !ok {
+			continue
+		}
+		r := image.Rect(int(b.X), int(b.Y), int(math.Ceil(b.X+b.W)), int(math.Ceil(b.Y+b.H))).Intersect(src.Bounds())
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				c := color.GrayModel.Convert(src.At(x, y)).(color.Gray)
+				if (c.Y == 0 || c.Y == 255) && color.GrayModel.Convert(pal.At(x, y)) != c {
+					t.Fatalf("-indexed changed the %q barcode pixel at (%d,%d)", cell.Cmd.Code, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestRotateImage(t *testing.T) {
+	// A small page is enough: every pixel is checked.
+	src := image.NewRGBA(image.Rect(0, 0, 7, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 7; x++ {
+			src.Set(x, y, color.RGBA{uint8(x * 30), uint8(y * 60), 0, 0xff})
+		}
+	}
+	w, h := 7, 4
+	tests := []struct {
+		degrees int
+		size    image.Point
+		at      func(x, y int) image.Point
+	}{
+		{0, image.Pt(w, h), func(x, y int) image.Point { return image.Pt(x, y) }},
+		{90, image.Pt(h, w), func(x, y int) image.Point { return image.Pt(h-1-y, x) }},
+		{180, image.Pt(w, h), func(x, y int) image.Point { return image.Pt(w-1-x, h-1-y) }},
+		{270, image.Pt(h, w), func(x, y int) image.Point { return image.Pt(y, w-1-x) }},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.degrees), func(t *testing.T) {
+			rot := rotateImage(src, tt.degrees)
+			if got := rot.Bounds().Size(); got != tt.size {
+				t.Fatalf("made a %v page from %dx%d, want %v", got, w, h, tt.size)
+			}
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					at := tt.at(x, y)
+					if src.At(x, y) != rot.At(rot.Bounds().Min.X+at.X, rot.Bounds().Min.Y+at.Y) {
+						t.Fatalf("lost the pixel at (%d,%d)", x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	errEncode := errors.New("encoder failed")
+	big := bytes.Repeat([]byte("x"), 64<<10) // past bufio's buffer, so part reaches the temp file
+	tests := []struct {
+		name     string
+		existing string // "" for no file at path beforehand
+		write    func(w io.Writer) error
+		wantErr  error
+		want     string
+	}{
+		{"success", "", func(w io.Writer) error { _, err := io.WriteString(w, "new"); return err }, nil, "new"},
+		{"replaces", "old", func(w io.Writer) error { _, err := io.WriteString(w, "new"); return err }, nil, "new"},
+		{"fails at once", "", func(w io.Writer) error { return errEncode }, errEncode, ""},
+		{"fails after writing", "", func(w io.Writer) error { w.Write(big); return errEncode }, errEncode, ""},
+		{"existing survives", "old", func(w io.Writer) error { w.Write(big); return errEncode }, errEncode, "old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "sheet.png")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := writeFileAtomic(path, tt.write); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("writeFileAtomic error = %v, want %v", err, tt.wantErr)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			got, err := os.ReadFile(path)
+			switch {
+			case tt.want == "":
+				if len(names) != 0 {
+					t.Errorf("left %q behind", names)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case string(got) != tt.want:
+				t.Errorf("file holds %.20q, want %q", got, tt.want)
+			case len(names) != 1:
+				t.Errorf("directory holds %q, want only sheet.png", names)
+			}
+			if tt.want != "" && tt.wantErr == nil {
+				fi, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Mode().Perm() != 0o644 {
+					t.Errorf("file mode = %v, want -rw-r--r--", fi.Mode())
+				}
+			}
+		})
+	}
+}
+
+func TestSaveImageUnsupportedLeavesNothing(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	if err := saveImage(filepath.Join(dir, "sheet.gif"), img, testOptions()); err == nil {
+		t.Fatal("saveImage accepted .gif")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("left %d file(s) behind", len(entries))
+	}
+}
+
+func TestEncodedDensity(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	// 72 and 96 DPI come to 2834.6 and 3779.5 pixels per metre, so they only
+	// survive as rounded values.
+	for _, dpi := range []float64{72, 96, 150, 300, 600} {
+		t.Run(fmt.Sprintf("png/%gdpi", dpi), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodePNG(&buf, img, dpi, png.DefaultCompression); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+			if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+				t.Fatalf("PNG doesn't decode: %v", err)
+			}
+			var types []string
+			var phys []byte
+			for _, c := range pngChunks(t, data) {
+				types = append(types, c.typ)
+				if c.typ == "pHYs" {
+					phys = c.data
+				}
+			}
+			if len(types) < 2 || types[1] != "pHYs" {
+				t.Fatalf("chunks %v, want pHYs straight after IHDR", types)
+			}
+			x, y := binary.BigEndian.Uint32(phys[0:]), binary.BigEndian.Uint32(phys[4:])
+			want := uint32(math.Round(dpi / 0.0254))
+			if x != want || y != want || phys[8] != 1 {
+				t.Fatalf("pHYs = %d x %d unit %d, want %d x %d per metre", x, y, phys[8], want, want)
+			}
+			if got := float64(x) * 0.0254; math.Abs(got-dpi) > 0.02 {
+				t.Errorf("pHYs reads back as %.3f DPI, want %g", got, dpi)
+			}
+		})
+		t.Run(fmt.Sprintf("jpeg/%gdpi", dpi), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeJPEG(&buf, img, dpi); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+			if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+				t.Fatalf("JPEG doesn't decode: %v", err)
+			}
+			app0 := data[2:]
+			if app0[0] != 0xFF || app0[1] != 0xE0 || string(app0[4:9]) != "JFIF\x00" {
+				t.Fatalf("no JFIF APP0 after SOI: % x", app0[:9])
+			}
+			x, y := binary.BigEndian.Uint16(app0[12:]), binary.BigEndian.Uint16(app0[14:])
+			if app0[11] != 1 || float64(x) != dpi || float64(y) != dpi {
+				t.Errorf("JFIF density = %d x %d unit %d, want %g x %g dots per inch", x, y, app0[11], dpi, dpi)
+			}
+		})
+	}
+}
+
+// pngChunk is one chunk of a PNG file.
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// pngChunks splits a PNG file after its 8-byte signature into chunks of
+// length, type, data and CRC, failing t on a bad CRC.
+func pngChunks(t *testing.T, data []byte) []pngChunk {
+	t.Helper()
+	var out []pngChunk
+	for off := 8; off+12 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[off:]))
+		if off+12+n > len(data) {
+			t.Fatalf("chunk at %d runs past the end of the file", off)
+		}
+		c := pngChunk{string(data[off+4 : off+8]), data[off+8 : off+8+n]}
+		if crc := binary.BigEndian.Uint32(data[off+8+n:]); crc != crc32.ChecksumIEEE(data[off+4:off+8+n]) {
+			t.Fatalf("%s chunk has a bad CRC", c.typ)
+		}
+		out = append(out, c)
+		off += 12 + n
+	}
+	return out
+}
+
+func TestFooterQRQuietZone(t *testing.T) {
+	opts := testOptions()
+	raw, err := qr.Encode(opts.FooterURL, opts.footerQRLevel(), qr.Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modules := raw.Bounds().Dx()
+	for _, size := range []int{modules + 2*qrQuietModules, 100, 512} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			img, err := footerQRImage(opts.FooterURL, size, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+				t.Fatalf("image is %dx%d, want %dx%d", b.Dx(), b.Dy(), size, size)
+			}
+			module := size / (modules + 2*qrQuietModules)
+			quiet := qrQuietModules * module
+			for y := 0; y < size; y++ {
+				for x := 0; x < size; x++ {
+					inside := x >= quiet && y >= quiet && x < size-quiet && y < size-quiet
+					if !inside && isDark(img.At(x, y)) {
+						t.Fatalf("dark pixel at (%d, %d), inside the %dpx quiet zone", x, y, quiet)
+					}
+				}
+			}
+			if res, err := decodeQR(img, rect{0, 0, float64(size), float64(size)}); err != nil || res.GetText() != opts.FooterURL {
+				t.Errorf("footer QR does not decode: %v", err)
+			}
+		})
+	}
+	if _, err := footerQRImage(opts.FooterURL, modules+2*qrQuietModules-1, opts); err == nil {
+		t.Error("drew a footer QR with no room for its quiet zone")
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	"github.com/fogleman/gg"
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Names the embedded Go fonts are registered under.
+const (
+	pdfFontFamily     = "goregular"
+	pdfBoldFontFamily = "gobold"
+	pdfMonoFontFamily = "gomono"
+)
+
+// pdfCanvas draws onto a single-page PDF in points, converting from page
+// pixels at the sheet DPI: page pixels are the one coordinate system of every
+// backend, so a sheet has the same physical size as PNG and as PDF. Text is
+// real, selectable text in the embedded Go Regular font and barcodes are
+// filled vector rectangles. Measuring is delegated to an off-screen raster
+// canvas so the layout matches the PNG.
+type pdfCanvas struct {
+	*rasterCanvas
+	pdf *fpdf.Fpdf
+	k   float64     // points per pixel
+	col color.Color // current colour, restored after drawing barcodes
+	// images maps each image drawn to the name it was embedded under, so an
+	// image drawn in every cell is embedded once.
+	images map[image.Image]string
+}
+
+// newPDFCanvas starts a PDF whose single page is width x height pixels at dpi.
+func newPDFCanvas(width, height int, dpi float64) *pdfCanvas {
+	k := 72 / dpi
+	pdf := fpdf.NewCustom(&fpdf.InitType{
+		UnitStr: "pt",
+		Size:    fpdf.SizeType{Wd: float64(width) * k, Ht: float64(height) * k},
+	})
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddUTF8FontFromBytes(pdfFontFamily, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(pdfBoldFontFamily, "", gobold.TTF)
+	pdf.AddUTF8FontFromBytes(pdfMonoFontFamily, "", gomono.TTF)
+	pdf.SetFont(pdfFontFamily, "", 12)
+	pdf.AddPage()
+
+	return &pdfCanvas{
+		rasterCanvas: &rasterCanvas{gg.NewContext(1, 1)},
+		pdf:          pdf,
+		k:            k,
+		col:          color.Black,
+		images:       map[image.Image]string{},
+	}
+}
+
+func (c *pdfCanvas) SetColor(col color.Color) {
+	c.col = col
+	r, g, b := rgb8(col)
+	c.pdf.SetDrawColor(r, g, b)
+	c.pdf.SetFillColor(r, g, b)
+	c.pdf.SetTextColor(r, g, b)
+}
+
+func (c *pdfCanvas) SetLineWidth(w float64) {
+	c.pdf.SetLineWidth(w * c.k)
+}
+
+func (c *pdfCanvas) SetDash(dashes ...float64) {
+	pts := make([]float64, len(dashes))
+	for i, d := range dashes {
+		pts[i] = d * c.k
+	}
+	c.pdf.SetDashPattern(pts, 0)
+}
+
+func (c *pdfCanvas) SetFont(f fontSpec) {
+	c.rasterCanvas.SetFont(f)
+	family := pdfFontFamily
+	switch {
+	case f.mono:
+		family = pdfMonoFontFamily
+	case f.bold:
+		family = pdfBoldFontFamily
+	}
+	c.pdf.SetFont(family, "", f.pixelSize()*c.k)
+}
+
+// DrawStringAnchored positions text like gg, but centers it on the PDF's own
+// glyph advances so anchored text lines up exactly in the vector output.
+func (c *pdfCanvas) DrawStringAnchored(s string, x, y, ax, ay float64) {
+	w := c.pdf.GetStringWidth(s) / c.k
+	x -= ax * w
+	y += ay * c.FontHeight()
+	c.pdf.Text(x*c.k, y*c.k, s)
+}
+
+func (c *pdfCanvas) StrokeRect(x, y, w, h float64) {
+	c.pdf.Rect(x*c.k, y*c.k, w*c.k, h*c.k, "D")
+}
+
+func (c *pdfCanvas) FillRect(x, y, w, h float64) {
+	c.pdf.Rect(x*c.k, y*c.k, w*c.k, h*c.k, "F")
+}
+
+func (c *pdfCanvas) DrawLine(x1, y1, x2, y2 float64) {
+	c.pdf.Line(x1*c.k, y1*c.k, x2*c.k, y2*c.k)
+}
+
+// DrawBarcode draws each bar (Code128) or run of dark modules (QR) as a
+// filled rectangle in black.
+func (c *pdfCanvas) DrawBarcode(bc image.Image, x, y float64) {
+	c.pdf.SetFillColor(0, 0, 0)
+	for _, r := range barcodeRects(bc) {
+		c.FillRect(x+r.X, y+r.Y, r.W, r.H)
+	}
+	r, g, b := rgb8(c.col)
+	c.pdf.SetFillColor(r, g, b)
+}
+
+// DrawImage embeds img as a PNG, keeping any transparency.
+func (c *pdfCanvas) DrawImage(img image.Image, x, y, w, h float64) {
+	name, ok := c.images[img]
+	if !ok {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			c.pdf.SetError(err)
+			return
+		}
+		name = fmt.Sprintf("image%d", len(c.images)+1)
+		c.pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "PNG"}, &buf)
+		c.images[img] = name
+	}
+	c.pdf.ImageOptions(name, x*c.k, y*c.k, w*c.k, h*c.k, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+}
+
+// rgb8 converts col to 8-bit RGB components.
+func rgb8(col color.Color) (r, g, b int) {
+	cr, cg, cb, _ := col.RGBA()
+	return int(cr >> 8), int(cg >> 8), int(cb >> 8)
+}
+
+// run is a horizontal span [start, end) of dark pixels.
+type run struct {
+	start, end int
+}
+
+// barcodeRects converts a scaled barcode image into dark rectangles relative
+// to its top-left corner. Each row's runs of dark pixels become rectangles and
+// identical consecutive rows are merged, so a Code128 bar or a row of QR
+// modules is a single rectangle rather than one per pixel.
+func barcodeRects(img image.Image) []rect {
+	b := img.Bounds()
+	var out []rect
+	var prev []run
+	var open []int // indexes into out of the rectangles started by prev
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		var runs []run
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !isDark(img.At(x, y)) {
+				continue
+			}
+			if n := len(runs); n > 0 && runs[n-1].end == x {
+				runs[n-1].end++
+			} else {
+				runs = append(runs, run{x, x + 1})
+			}
+		}
+
+		if y > b.Min.Y && equalRuns(runs, prev) {
+			for _, i := range open {
+				out[i].H++
+			}
+			continue
+		}
+		open = open[:0]
+		for _, r := range runs {
+			open = append(open, len(out))
+			out = append(out, rect{float64(r.start - b.Min.X), float64(y - b.Min.Y), float64(r.end - r.start), 1})
+		}
+		prev = runs
+	}
+	return out
+}
+
+func equalRuns(a, b []run) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isDark reports whether c is closer to black than white.
+func isDark(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r+g+b < 3*0x8000
+}
+
+// savePDF renders the sheet pages as a vector PDF to path, followed by the
+// appendix pages when opts.Appendix is set and the index pages when
+// opts.Index is. The report covers every sheet page.
+func savePDF(path string, cmds []GitCmd, opts Options) (*renderReport, error) {
+	width, height := opts.pagePx()
+	c := newPDFCanvas(width, height, opts.DPI)
+	c.pdf.SetTitle(opts.Title, true)
+	c.pdf.SetCreator(defaultFooterURL, true)
+
+	var report *renderReport
+	for i, page := range sheetPages(cmds, opts) {
+		if i > 0 {
+			c.pdf.AddPage()
+		}
+		pageReport, err := drawSheet(c, page, opts.onPage(i))
+		if err != nil {
+			return nil, err
+		}
+		if report == nil {
+			report = pageReport
+		} else {
+			report.Cells = append(report.Cells, pageReport.Cells...)
+		}
+	}
+	if opts.Appendix {
+		pages := paginateAppendix(c, cmds, opts)
+		for i, lines := range pages {
+			c.pdf.AddPage()
+			drawAppendixPage(c, lines, i+1, len(pages), opts)
+		}
+	}
+	if opts.Index {
+		newPage := func() canvas {
+			c.pdf.AddPage()
+			return c
+		}
+		if err := drawIndex(cmds, opts, newPage); err != nil {
+			return nil, err
+		}
+	}
+	return report, writeFileAtomic(path, c.pdf.Output)
+}
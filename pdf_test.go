@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestPNGAndPDFLayoutMatch(t *testing.T) {
+	quietLog(t)
+	opts := testOptions()
+	page := sheetPages(builtinCommands(t), opts)[0]
+	dc, raster, err := renderSheet(page, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	width, height := opts.pagePx()
+	c := newPDFCanvas(width, height, opts.DPI)
+	vector, err := drawSheet(c, page, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mm := func(px float64) float64 { return px / opts.DPI * 25.4 }
+	ptMM := func(pt float64) float64 { return pt / 72 * 25.4 }
+	if b := dc.Image().Bounds(); b.Dx() != width || b.Dy() != height {
+		t.Fatalf("PNG page is %dx%dpx, want %dx%dpx", b.Dx(), b.Dy(), width, height)
+	}
+	if len(raster.Cells) != len(vector.Cells) || raster.Footer != vector.Footer {
+		t.Fatal("PNG and PDF lay the page out differently")
+	}
+	for i, cell := range raster.Cells {
+		for el, box := range cell.Elements {
+			if vector.Cells[i].Elements[el] != box {
+				t.Errorf("%q %s sits at %+v in the PNG but %+v in the PDF", cell.Cmd.Code, el, box, vector.Cells[i].Elements[el])
+			}
+		}
+	}
+	f := opts.font(opts.fontSize(opts.LabelSize, 24))
+	c.SetFont(f)
+	if pt, _ := c.pdf.GetFontSize(); math.Abs(ptMM(pt)-mm(f.pixelSize())) > 0.001 {
+		t.Errorf("label text is %.3fmm in the PNG but %.3fmm in the PDF", mm(f.pixelSize()), ptMM(pt))
+	}
+}
+
+// mediaBoxRE matches a PDF MediaBox and captures its width and height.
+var mediaBoxRE = regexp.MustCompile(`/MediaBox \[0 0 ([0-9.]+) ([0-9.]+)\]`)
+
+func TestPNGAndPDFPageSize(t *testing.T) {
+	quietLog(t)
+	tests := []struct {
+		name string
+		w, h float64 // inches
+		dpi  float64
+	}{
+		{"A4", a4WidthInches, a4HeightInches, 300},
+		{"A4", a4WidthInches, a4HeightInches, 150},
+		{"Letter", 8.5, 11, 600},
+		{"A6", 4.13, 5.83, 96},
+		{"card", 3.5, 2, 203}, // a thermal printer's DPI, no whole pixels per metre
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%gdpi", tt.name, tt.dpi), func(t *testing.T) {
+			opts := testOptions()
+			opts.PageWidthIn, opts.PageHeightIn, opts.DPI = tt.w, tt.h, tt.dpi
+			cmds := builtinCommands(t)[:4]
+			dir := t.TempDir()
+
+			pdfPath := filepath.Join(dir, "sheet.pdf")
+			if _, err := savePDF(pdfPath, cmds, opts); err != nil {
+				t.Fatal(err)
+			}
+			pngPath := filepath.Join(dir, "sheet.png")
+			dc, _, err := renderSheet(cmds, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := saveImage(pngPath, dc.Image(), opts); err != nil {
+				t.Fatal(err)
+			}
+
+			// The PNG's size in mm, from its pixels and its own pHYs.
+			data, err := os.ReadFile(pngPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := png.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ppm uint32
+			for _, c := range pngChunks(t, data) {
+				if c.typ == "pHYs" {
+					ppm = binary.BigEndian.Uint32(c.data)
+				}
+			}
+			if ppm == 0 {
+				t.Fatal("PNG has no pHYs")
+			}
+			pngW, pngH := float64(cfg.Width)/float64(ppm)*1000, float64(cfg.Height)/float64(ppm)*1000
+
+			// The PDF's size in mm, from its MediaBox in points.
+			data, err = os.ReadFile(pdfPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			boxes := mediaBoxRE.FindAllSubmatch(data, -1)
+			if len(boxes) == 0 {
+				t.Fatal("PDF has no MediaBox")
+			}
+			for _, box := range boxes {
+				pw, _ := strconv.ParseFloat(string(box[1]), 64)
+				ph, _ := strconv.ParseFloat(string(box[2]), 64)
+				pdfW, pdfH := pw/72*25.4, ph/72*25.4
+				// MediaBox is written to 0.01pt, and pHYs rounds to whole
+				// pixels per metre, off by up to half of one in ppm.
+				tolW, tolH := 0.01+pngW*0.5/float64(ppm), 0.01+pngH*0.5/float64(ppm)
+				if math.Abs(pdfW-pngW) > tolW || math.Abs(pdfH-pngH) > tolH {
+					t.Errorf("PNG page is %.3fx%.3fmm, PDF MediaBox %.3fx%.3fmm", pngW, pngH, pdfW, pdfH)
+				}
+			}
+			if want := tt.w * 25.4; math.Abs(pngW-want) > 25.4/tt.dpi+0.02 {
+				t.Errorf("PNG page is %.3fmm wide, want %.3fmm to within a pixel", pngW, want)
+			}
+		})
+	}
+}
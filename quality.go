@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+// Quiet zones the symbology specifications ask for, in modules.
+const (
+	code128QuietModules = 10
+	qrQuietModules      = 4
+)
+
+// qualityThresholds are the limits -quality-report flags a barcode below.
+type qualityThresholds struct {
+	MinModuleMM float64 // printed module width
+	MinModulePx int     // module width in output pixels; below 2 the bars can't be even
+}
+
+// qualityResult is the predicted scannability of one command's barcode.
+type qualityResult struct {
+	Cmd       GitCmd
+	ModulePx  int
+	ModuleMM  float64
+	Quiet     float64 // free space round the symbol, in modules
+	NeedQuiet int
+	Level     string // QR error correction level, "" for Code128
+	BestLevel string // highest level that fits in the same QR size
+	Problems  []string
+}
+
+// qrLevelNames are the error correction levels, weakest first.
+var qrLevelNames = []string{"L", "M", "Q", "H"}
+
+// qualityReport works out, for each of cmds as laid out by opts, the module
+// size and quiet zone its barcode gets on paper and, for QRs, how much error
+// correction it could carry at no extra size, and flags those below t.
+func qualityReport(cmds []GitCmd, opts Options, t qualityThresholds) ([]qualityResult, error) {
+	var out []qualityResult
+	for i, page := range sheetPages(cmds, opts) {
+		_, report, err := renderSheet(page, opts.onPage(i))
+		if err != nil {
+			return nil, err
+		}
+		for _, cell := range report.Cells {
+			out = append(out, cellQuality(cell, opts, t))
+		}
+	}
+	return out, nil
+}
+
+// cellQuality measures the barcode drawn in one cell.
+func cellQuality(cell cellReport, opts Options, t qualityThresholds) qualityResult {
+	r := qualityResult{Cmd: cell.Cmd}
+	box, ok := cell.Elements[cellBarcode]
+	raw, err := encodeRaw(cell.Cmd, opts)
+	if cell.Err != nil || !ok || err != nil {
+		r.Problems = append(r.Problems, "not drawn")
+		return r
+	}
+	code128 := opts.isCode128(cell.Cmd)
+	modules := raw.Bounds().Dx()
+	// barcode.Scale draws whole pixels per module and centres the symbol in
+	// what is left over.
+	r.ModulePx = int(box.W) / modules
+	r.ModuleMM = float64(r.ModulePx) / opts.DPI * 25.4
+	side := float64(modules * r.ModulePx)
+	symbol := rect{box.X + (box.W-side)/2, box.Y, side, box.H}
+	if !code128 {
+		symbol.Y, symbol.H = box.Y+(box.H-side)/2, side
+	}
+	r.Quiet = quietZone(symbol, cell, !code128) / math.Max(float64(r.ModulePx), 1)
+
+	r.NeedQuiet = qrQuietModules
+	if code128 {
+		r.NeedQuiet = code128QuietModules
+	} else {
+		r.Level, r.BestLevel = qrHeadroom(raw.Content(), modules, cell.Cmd, opts)
+	}
+
+	if r.ModuleMM < t.MinModuleMM {
+		r.Problems = append(r.Problems, fmt.Sprintf("module under %gmm", t.MinModuleMM))
+	}
+	if r.ModulePx < t.MinModulePx {
+		r.Problems = append(r.Problems, fmt.Sprintf("module under %dpx", t.MinModulePx))
+	}
+	if r.Quiet < float64(r.NeedQuiet) {
+		r.Problems = append(r.Problems, fmt.Sprintf("quiet zone under %d modules", r.NeedQuiet))
+	}
+	return r
+}
+
+// quietZone is the free space in pixels between symbol and the nearest cell
+// edge or other element of cell, beside it and, with vertical, above and
+// below it too.
+func quietZone(symbol rect, cell cellReport, vertical bool) float64 {
+	c := cell.Cell
+	left, right := symbol.X-c.X, c.X+c.W-(symbol.X+symbol.W)
+	top, bottom := symbol.Y-c.Y, c.Y+c.H-(symbol.Y+symbol.H)
+	for el, b := range cell.Elements {
+		if el == cellBarcode {
+			continue
+		}
+		if b.Y < symbol.Y+symbol.H && symbol.Y < b.Y+b.H {
+			if b.X+b.W <= symbol.X {
+				left = math.Min(left, symbol.X-(b.X+b.W))
+			} else if b.X >= symbol.X+symbol.W {
+				right = math.Min(right, b.X-(symbol.X+symbol.W))
+			}
+		}
+		if b.X < symbol.X+symbol.W && symbol.X < b.X+b.W {
+			if b.Y+b.H <= symbol.Y {
+				top = math.Min(top, symbol.Y-(b.Y+b.H))
+			} else if b.Y >= symbol.Y+symbol.H {
+				bottom = math.Min(bottom, b.Y-(symbol.Y+symbol.H))
+			}
+		}
+	}
+	q := math.Min(left, right)
+	if vertical {
+		q = math.Min(q, math.Min(top, bottom))
+	}
+	return math.Max(q, 0)
+}
+
+// qrHeadroom returns the QR's error correction level and the highest level
+// content still encodes at with the same number of modules.
+func qrHeadroom(content string, modules int, cmd GitCmd, opts Options) (level, best string) {
+	current, encoding := opts.qrSettings(cmd)
+	for _, name := range qrLevelNames {
+		if qrLevels[name] == current {
+			level = name
+		}
+		raw, err := qr.Encode(content, qrLevels[name], qrEncodings[encoding])
+		if err == nil && raw.Bounds().Dx() <= modules {
+			best = name
+		}
+	}
+	return level, best
+}
+
+// printQualityReport writes one line per command and an overall verdict,
+// and returns how many commands failed.
+func printQualityReport(w io.Writer, results []qualityResult) (failed int, err error) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tMODULE\t\tQUIET\tEC\tCODE\tPROBLEMS")
+	for _, r := range results {
+		status := "ok"
+		if len(r.Problems) > 0 {
+			status = "FAIL"
+			failed++
+		}
+		ec := "-"
+		if r.Level != "" {
+			ec = r.Level
+			if r.BestLevel != r.Level {
+				ec += " (" + r.BestLevel + " fits)"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%dpx\t%.2fmm\t%.1f/%d\t%s\t%q\t%s\n", status, r.ModulePx, r.ModuleMM, r.Quiet, r.NeedQuiet, ec, r.Cmd.Code, strings.Join(r.Problems, ", "))
+	}
+	verdict := "PASS"
+	if failed > 0 {
+		verdict = "FAIL"
+	}
+	fmt.Fprintf(tw, "\n%s: %d of %d commands meet the thresholds\n", verdict, len(results)-failed, len(results))
+	return failed, tw.Flush()
+}
@@ -0,0 +1,748 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// renderSheet draws the command grid, title and footer onto a new page-sized context.
+// The report lists where each command landed and which ones were skipped.
+func renderSheet(cmds []GitCmd, opts Options) (*gg.Context, *renderReport, error) {
+	if err := opts.checkPixels(); err != nil {
+		return nil, nil, err
+	}
+	width, height := opts.pagePx()
+
+	dc := gg.NewContext(width, height)
+
+	// Background
+	dc.SetRGB(1, 1, 1)
+	dc.Clear()
+
+	report, err := drawSheet(&rasterCanvas{dc}, cmds, opts)
+	return dc, report, err
+}
+
+// drawSheet lays out and draws the sheet onto c, which must be opts.pagePx() in size.
+func drawSheet(dc canvas, cmds []GitCmd, opts Options) (*renderReport, error) {
+	width, height := opts.pagePx()
+	report := &renderReport{Page: rect{0, 0, float64(width), float64(height)}}
+	if opts.Template != nil {
+		if opts.page > 0 {
+			report.Header = drawTemplateHeader(dc, *opts.Template, opts)
+		}
+		return report, drawTemplate(dc, cmds, *opts.Template, opts, report)
+	}
+
+	// Tighter margins to reduce white space
+	margin := opts.px(60)
+
+	// Title (larger font), centered in a band above the grid
+	dc.SetColor(color.Black)
+	titleFont, titleLines, err := fitTitle(dc, opts, float64(width)-2*margin)
+	if err != nil {
+		return nil, err
+	}
+	dc.SetFont(titleFont)
+	titleHeight := wrappedHeight(dc, len(titleLines), titleSpacing)
+	titleBand := math.Max(margin, titleHeight+margin/2)
+	lineY := (titleBand - titleHeight) / 2
+	for _, line := range titleLines {
+		dc.DrawStringAnchored(line, float64(width)/2, lineY, 0.5, 1)
+		lineY += dc.FontHeight() * titleSpacing
+	}
+
+	top := titleBand
+	bottom := float64(height) - opts.bottomMargin()
+	left := margin
+	right := float64(width) - margin
+	area := rect{left, top, right - left, bottom - top}
+
+	draw := drawGrid
+	if opts.Layout == layoutList {
+		draw = drawList
+	}
+	if err := draw(dc, cmds, area, opts, report); err != nil {
+		return report, err
+	}
+	if opts.CropMarks {
+		report.CropMarks = drawCropMarks(dc, area, math.Min(margin, opts.bottomMargin()), opts)
+	}
+	if opts.NoFooter {
+		return report, nil
+	}
+
+	// --- Footer: repo QR + text --- (kept inside the page)
+	// Keep the QR comfortably inside the bottom margin, above it and centered
+	footerSize := footerQRSize(width, opts)
+	fbY, ok := footerQRTop(float64(height), margin, footerSize, opts)
+	if !ok {
+		log.Printf("warning: the %dpx footer QR doesn't fit on the %dx%dpx page; leaving it off", footerSize, width, height)
+		return report, nil
+	}
+	box, err := drawFooterQR(dc, float64(width)/2, fbY, 0.5, footerSize, opts)
+	if err != nil {
+		log.Printf("QR error for footer: %v", err)
+	} else {
+		report.Footer = box
+		// Footer text just above page bottom
+		drawFooterText(dc, float64(width)/2, float64(height)-opts.px(12), 0.5, 0, float64(width)-2*margin, opts)
+	}
+
+	if opts.SymbologyLegend {
+		// In the bottom margin, left of the footer QR and the URL under it.
+		dc.SetFont(opts.font(opts.fontSize(opts.FooterTextSize, 12)))
+		urlW, _ := dc.MeasureString(opts.FooterURL)
+		maxW := float64(width)/2 - math.Max(float64(footerSize), urlW)/2 - margin - opts.px(20)
+		report.Legend = drawLegend(dc, cmds, margin, float64(height)-margin/2, maxW, opts)
+	}
+
+	return report, nil
+}
+
+// noFooterMargin is the bottom margin, in pixels before Scale, the grid
+// keeps under NoFooter.
+const noFooterMargin = 20
+
+// bottomMargin is the space below the grid: the footer's band, or a thin edge
+// under NoFooter.
+func (o Options) bottomMargin() float64 {
+	if o.NoFooter {
+		return o.px(noFooterMargin)
+	}
+	return o.px(60)
+}
+
+// footerQRTop is the top edge of a size pixel footer QR, a little into the
+// bottom margin, clamped so the whole QR stays on a page height tall. It
+// reports false when the page is too short to hold it.
+func footerQRTop(height, margin float64, size int, opts Options) (float64, bool) {
+	y := height - margin - float64(size) + opts.px(4)
+	y = math.Min(y, height-float64(size))
+	return math.Max(y, 0), size > 0 && float64(size) <= height
+}
+
+// footerQRSize is the side of the footer QR on a page width pixels wide: a
+// sixth of the width, capped to fit the bottom margin.
+func footerQRSize(width int, opts Options) int {
+	return int(math.Min(float64(width)*0.16, opts.px(60)*0.9))
+}
+
+// drawFooterQR draws the footer QR, size pixels square, with its top edge at
+// y and the point ax of the way across it at x (0 = left, 0.5 = centre), and
+// returns its box.
+func drawFooterQR(dc canvas, x, y, ax float64, size int, opts Options) (rect, error) {
+	bc, err := encodeQR(opts.FooterURL, size, opts.footerQRLevel())
+	if err != nil {
+		return rect{}, err
+	}
+	w, h := float64(bc.Bounds().Dx()), float64(bc.Bounds().Dy())
+	box := rect{opts.snap(x - ax*w), opts.snap(y), w, h}
+	dc.DrawBarcode(bc, box.X, box.Y)
+	if opts.QRLogo != nil {
+		drawQRLogo(dc, box, opts.QRLogo, opts.footerQRLevel())
+	}
+	return box, nil
+}
+
+// drawFooterText draws the footer URL anchored at (x, y) like
+// DrawStringAnchored, shrunk if a long URL won't fit in maxWidth.
+func drawFooterText(dc canvas, x, y, ax, ay, maxWidth float64, opts Options) {
+	dc.SetColor(opts.FooterTextColor)
+	if opts.FooterTextColor == nil {
+		dc.SetColor(color.Black)
+	}
+	for size := opts.fontSize(opts.FooterTextSize, 12); ; size *= 0.95 {
+		dc.SetFont(opts.font(size))
+		if w, _ := dc.MeasureString(opts.FooterURL); w <= maxWidth || size < 1 {
+			break
+		}
+	}
+	dc.DrawStringAnchored(opts.FooterURL, x, y, ax, ay)
+}
+
+// headerStripQR is the size in pixels of the footer QR in the header strip.
+const headerStripQR = 150
+
+// headerStrip is the height of the header strip at the top of the pages
+// after the first, or zero without RepeatFooterAsHeader.
+func (o Options) headerStrip() float64 {
+	if !o.RepeatFooterAsHeader {
+		return 0
+	}
+	return o.px(headerStripQR + 40)
+}
+
+// drawHeaderStrip draws the footer QR with the URL beside it as a compact
+// strip across the top of a page after the first, when RepeatFooterAsHeader
+// is set, so a page separated from the rest still says where it came from.
+// The strip is height pixels tall, the QR filling it but for a gap above and
+// below. It returns the QR's box, zero without RepeatFooterAsHeader.
+func drawHeaderStrip(dc canvas, height float64, opts Options) (rect, error) {
+	if !opts.RepeatFooterAsHeader {
+		return rect{}, nil
+	}
+	width, _ := opts.pagePx()
+	margin := opts.px(60)
+	gap := opts.px(20)
+	box, err := drawFooterQR(dc, margin, gap, 0, int(height-2*gap), opts)
+	if err != nil {
+		return rect{}, err
+	}
+	textX := box.X + box.W + gap
+	drawFooterText(dc, textX, box.Y+box.H/2, 0, 0.5, float64(width)-margin-textX, opts)
+
+	ruleY := height - gap/2
+	dc.SetColor(color.RGBA{R: 200, G: 200, B: 200, A: 255})
+	dc.SetLineWidth(opts.px(1))
+	dc.DrawLine(margin, ruleY, float64(width)-margin, ruleY)
+	return box, nil
+}
+
+// drawTemplateHeader draws the header strip on a label sheet page after the
+// first. The labels sit where the sheet's die cut puts them, so the strip
+// shrinks into the top margin above them, or is left off with a warning when
+// the margin can't hold a readable QR.
+func drawTemplateHeader(dc canvas, t labelTemplate, opts Options) rect {
+	strip := math.Min(mmToPx(t.TopMM, opts.DPI), opts.headerStrip())
+	box, err := drawHeaderStrip(dc, strip, opts)
+	if err != nil {
+		log.Printf("warning: no room for -repeat-footer-as-header in the %gmm top margin of template %s; leaving it off: %v", t.TopMM, t.Name, err)
+	}
+	return box
+}
+
+// descColumnCols is the most columns, fewer than cols, at which every barcode
+// of cmds fits beside its -desc-column description in area, or an error
+// naming the commands that don't fit even at one column.
+func descColumnCols(cmds []GitCmd, cols int, area rect, opts Options) (int, error) {
+	for c := cols - 1; c >= 1; c-- {
+		if len(belowMinModule(cmds, c, area.W, area.H, opts)) == 0 {
+			return c, nil
+		}
+	}
+	bad := belowMinModule(cmds, 1, area.W, area.H, opts)
+	return 0, fmt.Errorf("-desc-column: %d barcode(s) don't fit beside their descriptions even in one column, e.g. %q; lower -desc-column-width", len(bad), bad[0])
+}
+
+// drawGrid draws cmds as a grid of cells filling area, recording each in report.
+func drawGrid(dc canvas, cmds []GitCmd, area rect, opts Options, report *renderReport) error {
+	// Layout: cols columns, N rows
+	cols := opts.Cols
+	if cols <= 0 {
+		cols = autoCols(cmds, area.W, area.H, opts)
+		log.Printf("auto columns: %d", cols)
+	} else if bad := belowMinModule(cmds, cols, area.W, area.H, opts); len(bad) > 0 && opts.DescColumn {
+		// Half a cell is often too narrow for a long Code128, and a cell
+		// without its barcode is no use, so give up columns rather than codes.
+		fit, err := descColumnCols(cmds, cols, area, opts)
+		if err != nil {
+			return err
+		}
+		log.Printf("-desc-column: %d barcode(s) too narrow at %d columns, e.g. %q; using %d", len(bad), cols, bad[0], fit)
+		cols = fit
+	} else if len(bad) > 0 {
+		log.Printf("warning: %d command(s) miss the barcode size constraints at %d columns, e.g. %q", len(bad), cols, bad[0])
+	}
+	rows := int(math.Ceil(float64(len(cmds)) / float64(cols)))
+
+	cellWidth := area.W / float64(cols)
+	cellHeight := area.H / float64(rows)
+	if opts.Balance {
+		stack, stackW := opts.cellStack(cellWidth)
+		opts.balance = balanceSizes(cmds, stackW, cellHeight, stack)
+	}
+
+	// In each cell:
+	// - If command is short: draw wide Code128 barcode
+	// - If command is long: draw square-ish QR
+
+	for i, cmd := range cmds {
+		col := opts.fillColumn(i, cols)
+		row := i / cols
+
+		x := area.X + float64(col)*cellWidth
+		y := area.Y + float64(row)*cellHeight
+
+		boxes, err := opts.drawCell(dc, cmd, rect{x, y, cellWidth, cellHeight})
+		if errors.Is(err, errCellOverflow) {
+			return err
+		}
+		if err != nil {
+			log.Printf("Barcode error for %q: %v", cmd.Code, err)
+		}
+		report.Cells = append(report.Cells, cellReport{
+			Cmd:      cmd,
+			Cell:     rect{x, y, cellWidth, cellHeight},
+			Elements: boxes,
+			Err:      err,
+		})
+	}
+
+	if opts.CutGuides {
+		drawCutGuides(dc, area, cols, rows, report.Page, opts)
+	}
+	return nil
+}
+
+// Grid fill orders, see Options.FillOrder.
+const (
+	fillLTR = "ltr"
+	fillRTL = "rtl"
+)
+
+// fillColumn is the column the i-th of a row-by-row fill lands in, counting
+// from the left.
+func (o Options) fillColumn(i, cols int) int {
+	if o.FillOrder == fillRTL {
+		return cols - 1 - i%cols
+	}
+	return i % cols
+}
+
+// drawCutGuides draws continuous lines across the whole page at every column
+// and row boundary of a cols x rows grid filling area, for guillotine cutting.
+func drawCutGuides(dc canvas, area rect, cols, rows int, page rect, opts Options) {
+	dc.SetColor(opts.CutGuideColor)
+	dc.SetLineWidth(opts.px(opts.CutGuideWidth))
+	for c := 0; c <= cols; c++ {
+		x := area.X + float64(c)*area.W/float64(cols)
+		dc.DrawLine(x, page.Y, x, page.Y+page.H)
+	}
+	for r := 0; r <= rows; r++ {
+		y := area.Y + float64(r)*area.H/float64(rows)
+		dc.DrawLine(page.X, y, page.X+page.W, y)
+	}
+}
+
+// minCropMarkMM is the shortest crop mark worth drawing; a margin with less
+// room than this after the offset gets none.
+const minCropMarkMM = 1
+
+// drawCropMarks draws a horizontal and a vertical trim mark off each corner
+// of area, pointing away from it so none reaches into the cells. They start
+// opts.CropMarkOffsetMM from the corner and are shortened, with a warning,
+// to stay inside a margin room pixels wide. It returns the marks' boxes.
+func drawCropMarks(dc canvas, area rect, room float64, opts Options) []rect {
+	offset := mmToPx(opts.CropMarkOffsetMM, opts.DPI)
+	length := mmToPx(opts.CropMarkLengthMM, opts.DPI)
+	if fit := room - offset; length > fit {
+		if fit < mmToPx(minCropMarkMM, opts.DPI) {
+			log.Printf("warning: the %.1fmm margin has no room for crop marks %.1fmm from the grid; none drawn", room/opts.DPI*25.4, opts.CropMarkOffsetMM)
+			return nil
+		}
+		log.Printf("warning: crop marks shortened from %.1fmm to %.1fmm to fit the margin", opts.CropMarkLengthMM, fit/opts.DPI*25.4)
+		length = fit
+	}
+
+	lw := opts.px(1)
+	dc.SetColor(color.Black)
+	dc.SetLineWidth(lw)
+	var marks []rect
+	for _, x := range []float64{area.X, area.X + area.W} {
+		for _, y := range []float64{area.Y, area.Y + area.H} {
+			// Away from the grid: -1 left of (above) it, +1 right of (below).
+			sx, sy := 1.0, 1.0
+			if x == area.X {
+				sx = -1
+			}
+			if y == area.Y {
+				sy = -1
+			}
+			hx0, hx1 := x+sx*offset, x+sx*(offset+length)
+			vy0, vy1 := y+sy*offset, y+sy*(offset+length)
+			dc.DrawLine(hx0, y, hx1, y)
+			dc.DrawLine(x, vy0, x, vy1)
+			marks = append(marks,
+				rect{math.Min(hx0, hx1), y - lw/2, length, lw},
+				rect{x - lw/2, math.Min(vy0, vy1), lw, length})
+		}
+	}
+	return marks
+}
+
+// parseHexColor parses a colour written as #rrggbb or #rgb.
+func parseHexColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	var r, g, b uint8
+	if n, err := fmt.Sscanf(hex, "%02x%02x%02x", &r, &g, &b); len(hex) != 6 || n != 3 || err != nil {
+		return nil, fmt.Errorf("bad colour %q (want #rrggbb or #rgb)", s)
+	}
+	return color.RGBA{R: r, G: g, B: b, A: 255}, nil
+}
+
+// How a title wider than the page is handled, see Options.TitleOverflow.
+const (
+	titleOverflowShrink = "shrink"
+	titleOverflowWrap   = "wrap"
+	titleOverflowClip   = "clip"
+)
+
+// titleSpacing is the line spacing of a wrapped title.
+const titleSpacing = 1.2
+
+// fitTitle returns the title font and lines to draw so the title fits within
+// maxWidth according to opts.TitleOverflow. Wrapping allows up to two lines,
+// shrinking the font further if the words still don't fit on two. Shrinking
+// stops at the -min-font-size floor, where opts.OverflowPolicy decides
+// between drawing the title as it is with a warning and failing.
+func fitTitle(dc canvas, opts Options, maxWidth float64) (fontSpec, []string, error) {
+	title := opts.Title
+	if title == "" {
+		title = defaultTitle
+	}
+	size := opts.fontSize(opts.TitleSize, 36)
+
+	for ; ; size *= 0.95 {
+		face := opts.font(size)
+		dc.SetFont(face)
+		var lines []string
+		switch opts.TitleOverflow {
+		case titleOverflowShrink:
+			lines = []string{title}
+			if w, _ := dc.MeasureString(title); w <= maxWidth {
+				return face, lines, nil
+			}
+		case titleOverflowWrap:
+			lines = dc.WordWrap(title, maxWidth)
+			if len(lines) <= 2 {
+				return face, lines, nil
+			}
+		default:
+			return face, []string{title}, nil
+		}
+
+		if size < 1 || !opts.aboveFontFloor(size*0.95) {
+			if opts.OverflowPolicy == overflowError {
+				return face, nil, fmt.Errorf("%w: title %q is too wide at the minimum font size", errCellOverflow, title)
+			}
+			log.Printf("warning: title %q is too wide at the minimum font size", title)
+			return face, lines, nil
+		}
+	}
+}
+
+// Cell elements that can be stacked with -cell-order.
+const (
+	cellLabel   = "label"
+	cellBarcode = "barcode"
+	cellDesc    = "desc"
+
+	// cellCheckbox and cellNumber are not stackable; they mark the practice
+	// checkbox and the -numbered sequence number in reports.
+	cellCheckbox = "checkbox"
+	cellNumber   = "number"
+)
+
+var defaultCellOrder = []string{cellLabel, cellBarcode, cellDesc}
+
+// cellGap is the vertical space between stacked cell elements.
+const cellGap = 15.0
+
+// labelSpacing is the line spacing of a multi-line label.
+const labelSpacing = 1.2
+
+// parseCellOrder parses a comma list such as "desc,barcode,label". Every
+// element must appear exactly once.
+func parseCellOrder(s string) ([]string, error) {
+	parts := strings.Split(s, ",")
+	seen := map[string]bool{}
+	var order []string
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		switch p {
+		case cellLabel, cellBarcode, cellDesc:
+		default:
+			return nil, fmt.Errorf("unknown cell element %q (want %s, %s or %s)", p, cellLabel, cellBarcode, cellDesc)
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("cell element %q listed twice", p)
+		}
+		seen[p] = true
+		order = append(order, p)
+	}
+	if len(order) != len(defaultCellOrder) {
+		return nil, fmt.Errorf("cell order %q must list %s, %s and %s", s, cellLabel, cellBarcode, cellDesc)
+	}
+	return order, nil
+}
+
+// rect is an axis-aligned box in page pixels.
+type rect struct {
+	X, Y, W, H float64
+}
+
+// contains reports whether o lies entirely inside r.
+func (r rect) contains(o rect) bool {
+	return o.X >= r.X && o.Y >= r.Y && o.X+o.W <= r.X+r.W && o.Y+o.H <= r.Y+r.H
+}
+
+// overlaps reports whether r and o share any area.
+func (r rect) overlaps(o rect) bool {
+	return r.X < o.X+o.W && o.X < r.X+r.W && r.Y < o.Y+o.H && o.Y < r.Y+r.H
+}
+
+// cellReport records how one command was drawn.
+type cellReport struct {
+	Cmd      GitCmd
+	Cell     rect
+	Elements map[string]rect // drawn element boxes keyed by cellLabel, cellBarcode, cellDesc
+	Err      error           // set when the command was skipped
+}
+
+// renderReport records what happened to every command on a rendered sheet.
+type renderReport struct {
+	Page   rect
+	Footer rect // the footer QR; zero when there is none
+	Header rect // the header strip QR; zero when there is none
+	Legend rect // the symbology legend; zero without one
+	Cells  []cellReport
+	// CropMarks are the boxes of the crop marks, each as wide as its line.
+	CropMarks []rect
+}
+
+// drawCell draws cmd into cell with the DrawCell hook, or drawCell without one.
+func (o Options) drawCell(dc canvas, cmd GitCmd, cell rect) (map[string]rect, error) {
+	if o.DrawCell != nil {
+		return o.DrawCell(dc, cmd, cell, o)
+	}
+	if o.DescColumn && !o.NoText && !o.NoDesc {
+		return drawDescColumnCell(dc, cmd, cell, o)
+	}
+	return drawCell(dc, cmd, cell.X, cell.Y, cell.W, cell.H, o)
+}
+
+// cellStack returns the options and width the label, barcode and
+// description stack of a grid cell cellWidth wide is drawn with: under
+// DescColumn, the part left of the description column and without it.
+func (o Options) cellStack(cellWidth float64) (Options, float64) {
+	if !o.DescColumn || o.NoText || o.NoDesc {
+		return o, cellWidth
+	}
+	o.NoDesc = true
+	return o, cellWidth * (1 - o.DescColumnWidth)
+}
+
+// drawDescColumnCell draws cmd's label and barcode as a cell on the left of
+// cell and its description left-aligned in a DescColumnWidth column on the
+// right, as in a printed manual.
+func drawDescColumnCell(dc canvas, cmd GitCmd, cell rect, opts Options) (map[string]rect, error) {
+	stack, stackW := opts.cellStack(cell.W)
+	descW := cell.W - stackW
+	boxes, err := drawCell(dc, cmd, cell.X, cell.Y, stackW, cell.H, stack)
+	if err != nil {
+		return nil, err
+	}
+	_, descSize := opts.cellFontSizes(cell.H)
+	col := rect{cell.X + cell.W - descW, cell.Y, descW, cell.H}
+	box, err := drawListDesc(dc, cmd, col, opts.px(8), descSize, 1.4, opts)
+	if err != nil {
+		return nil, err
+	}
+	boxes[cellDesc] = box
+	dc.SetLineWidth(opts.px(0.6))
+	dc.SetColor(color.RGBA{R: 220, G: 220, B: 220, A: 255})
+	dc.StrokeRect(cell.X, cell.Y, cell.W, cell.H)
+	return boxes, nil
+}
+
+// drawCell draws one command into the cell at (x, y): a light border, then the
+// label, barcode and description stacked in opts.CellOrder and centered vertically.
+// It returns the boxes the elements were drawn in.
+func drawCell(dc canvas, cmd GitCmd, x, y, cellWidth, cellHeight float64, opts Options) (map[string]rect, error) {
+	// Light cell boundary
+	dc.SetLineWidth(opts.px(0.6))
+	dc.SetColor(color.RGBA{R: 220, G: 220, B: 220, A: 255})
+	dc.StrokeRect(x, y, cellWidth, cellHeight)
+
+	// Barcode generation (specific to type)
+	scaled, err := encodeCell(cmd, cellWidth, cellHeight, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	label := opts.label(cmd)
+	labelLines := splitLines(label)
+	labelSize, descSize := opts.cellFontSizes(cellHeight)
+	descPad := opts.px(8)
+	labelMaxW := opts.labelMaxWidth(cellWidth, descPad)
+	labelSize = opts.fitLabelSize(dc, labelLines, labelSize, labelMaxW)
+	descWidth := cellWidth - 2*descPad
+	switch {
+	case cmd.DescWidth > 1:
+		descWidth = math.Min(descWidth, opts.px(cmd.DescWidth))
+	case cmd.DescWidth > 0:
+		descWidth = math.Min(descWidth, cellWidth*cmd.DescWidth)
+	}
+	gap := opts.px(cellGap)
+	const descSpacing = 1.4
+
+	order := opts.CellOrder
+	if len(order) == 0 {
+		order = defaultCellOrder
+	}
+	if opts.NoText {
+		order = []string{cellBarcode}
+	} else if opts.NoDesc {
+		order = withoutElement(order, cellDesc)
+	}
+	if label == "" {
+		order = withoutElement(order, cellLabel)
+	}
+
+	// Measure every element so the stack can be centered in the cell, with
+	// the text fonts scaled by textScale.
+	var labelFace, descFace fontSpec
+	var labelFit []string // labelLines ellipsized to labelMaxW
+	var widths, heights map[string]float64
+	var total, labelLineHeight float64
+	measure := func(textScale float64) {
+		labelFace = opts.font(labelSize * textScale)
+		descFace = opts.font(descSize * textScale)
+		widths = map[string]float64{}
+		heights = map[string]float64{}
+		dc.SetFont(labelFace)
+		labelFit = ellipsizeLines(dc, labelLines, labelMaxW)
+		widths[cellLabel], heights[cellLabel] = measureLines(dc, labelFit, labelSpacing)
+		labelLineHeight = dc.FontHeight()
+		widths[cellBarcode], heights[cellBarcode] = float64(scaled.Bounds().Dx()), float64(scaled.Bounds().Dy())
+		dc.SetFont(descFace)
+		widths[cellDesc], heights[cellDesc] = measureLines(dc, dc.WordWrap(cmd.Description, descWidth), descSpacing)
+		total = gap * float64(len(order)-1)
+		for _, el := range order {
+			total += heights[el]
+		}
+	}
+	measure(1)
+
+	// Content taller than the padded cell would run into the next row.
+	if avail := cellHeight - 2*descPad; total > avail {
+		switch opts.OverflowPolicy {
+		case overflowShrink:
+			smallest := math.Min(labelSize, descSize)
+			for textScale := 0.95; total > avail && textScale > 0 && opts.aboveFontFloor(smallest*textScale); textScale -= 0.05 {
+				measure(textScale)
+			}
+			if total > avail {
+				log.Printf("warning: %q still overflows its cell by %.0fpx at the minimum font size", cmd.Code, total-avail)
+			}
+		case overflowError:
+			return nil, fmt.Errorf("%w: %q needs %.0fpx, cell has %.0fpx", errCellOverflow, cmd.Code, total, avail)
+		default:
+			log.Printf("warning: %q overflows its cell by %.0fpx", cmd.Code, total-avail)
+		}
+	}
+
+	cx := x + cellWidth/2
+	cy := y + (cellHeight-total)/2
+	boxes := map[string]rect{}
+	dc.SetColor(color.Black)
+	for _, el := range order {
+		boxes[el] = rect{cx - widths[el]/2, cy, widths[el], heights[el]}
+		switch el {
+		case cellLabel:
+			dc.SetFont(labelFace)
+			drawLines(dc, labelFit, cx, cy, 0.5, labelSpacing)
+		case cellBarcode:
+			bx, by := opts.snap(cx-widths[el]/2), opts.snap(cy)
+			dc.DrawBarcode(scaled, bx, by)
+			boxes[el] = rect{bx, by, widths[el], heights[el]}
+			drawCellLogo(dc, cmd, boxes[el], opts)
+		case cellDesc:
+			dc.SetFont(descFace)
+			drawStringWrapped(dc, cmd.Description, cx-descWidth/2, cy, descWidth, descSpacing)
+		}
+		cy += heights[el] + gap
+	}
+
+	// Practice checkbox, sized from the label text. Barcode-only cells have no
+	// label to practise against, so they get none.
+	if opts.Practice && !opts.NoText {
+		box := cornerBox(rect{x, y, cellWidth, cellHeight}, cornerTopLeft, labelLineHeight, labelLineHeight, descPad)
+		dc.SetLineWidth(opts.px(2))
+		dc.StrokeRect(box.X, box.Y, box.W, box.H)
+		boxes[cellCheckbox] = box
+	}
+	if box, ok := drawCellNumber(dc, cmd, rect{x, y, cellWidth, cellHeight}, descPad, opts); ok {
+		boxes[cellNumber] = box
+	}
+	return boxes, nil
+}
+
+// drawCellNumber draws cmd's sequence number in opts.NumberCorner of cell
+// under Numbered, and returns its box.
+func drawCellNumber(dc canvas, cmd GitCmd, cell rect, inset float64, opts Options) (rect, bool) {
+	if !opts.Numbered || cmd.seq == 0 {
+		return rect{}, false
+	}
+	text := strconv.Itoa(cmd.seq)
+	dc.SetFont(opts.boldFont(opts.fontSize(opts.NumberSize, 20)))
+	w, _ := dc.MeasureString(text)
+	box := cornerBox(cell, opts.NumberCorner, w, dc.FontHeight(), inset)
+	dc.SetColor(color.Gray{Y: 96})
+	dc.DrawStringAnchored(text, box.X, box.Y, 0, 1)
+	return box, true
+}
+
+// numberCommands returns a copy of cmds numbered in order for -numbered.
+func numberCommands(cmds []GitCmd) []GitCmd {
+	out := append([]GitCmd(nil), cmds...)
+	for i := range out {
+		out[i].seq = i + 1
+	}
+	return out
+}
+
+// withoutElement returns order with el removed.
+func withoutElement(order []string, el string) []string {
+	var out []string
+	for _, e := range order {
+		if e != el {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// What drawCell does when a cell's content is taller than the cell, see
+// Options.OverflowPolicy.
+const (
+	overflowShrink = "shrink"
+	overflowWarn   = "warn"
+	overflowError  = "error"
+)
+
+// errCellOverflow is returned under overflowError when a cell or the title doesn't fit.
+var errCellOverflow = errors.New("content does not fit")
+
+// Corners a small cell annotation can be pinned to.
+const (
+	cornerTopLeft     = "top-left"
+	cornerTopRight    = "top-right"
+	cornerBottomLeft  = "bottom-left"
+	cornerBottomRight = "bottom-right"
+)
+
+// cornerBox returns a w x h box inset from the given corner of cell.
+func cornerBox(cell rect, corner string, w, h, inset float64) rect {
+	box := rect{cell.X + inset, cell.Y + inset, w, h}
+	switch corner {
+	case cornerTopRight, cornerBottomRight:
+		box.X = cell.X + cell.W - inset - w
+	}
+	switch corner {
+	case cornerBottomLeft, cornerBottomRight:
+		box.Y = cell.Y + cell.H - inset - h
+	}
+	return box
+}
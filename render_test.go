@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestFillOrder(t *testing.T) {
+	quietLog(t)
+	for order, wantCols := range map[string][]int{fillLTR: {0, 1, 0, 1}, fillRTL: {1, 0, 1, 0}} {
+		t.Run(order, func(t *testing.T) {
+			opts := testOptions()
+			opts.Cols, opts.FillOrder = 2, order
+			_, report, err := renderSheet(matrixCommands, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			area := report.Cells[0].Cell
+			for _, c := range report.Cells {
+				area.X, area.Y = math.Min(area.X, c.Cell.X), math.Min(area.Y, c.Cell.Y)
+			}
+			for i, c := range report.Cells {
+				col := int(math.Round((c.Cell.X - area.X) / c.Cell.W))
+				row := int(math.Round((c.Cell.Y - area.Y) / c.Cell.H))
+				if col != wantCols[i] || row != i/2 {
+					t.Errorf("%q in row %d column %d, want row %d column %d", c.Cmd.Code, row, col, i/2, wantCols[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSnap(t *testing.T) {
+	quietLog(t)
+	for _, dpi := range []float64{150, 300, 600} {
+		t.Run(fmt.Sprintf("%gdpi", dpi), func(t *testing.T) {
+			opts := testOptions()
+			opts.DPI, opts.Snap = dpi, true
+			_, report, err := renderSheet(builtinCommands(t), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, cell := range report.Cells {
+				if b, ok := cell.Elements[cellBarcode]; ok && (b.X != math.Round(b.X) || b.Y != math.Round(b.Y)) {
+					t.Errorf("%q barcode at (%g, %g) is off the pixel grid", cell.Cmd.Code, b.X, b.Y)
+				}
+			}
+		})
+	}
+}
+
+func TestNoFooter(t *testing.T) {
+	quietLog(t)
+	cmds := builtinCommands(t)
+	gridBottom := func(noFooter bool) (float64, *renderReport) {
+		opts := testOptions()
+		opts.NoFooter = noFooter
+		_, report, err := renderSheet(sheetPages(cmds, opts)[0], opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var bottom float64
+		for _, c := range report.Cells {
+			bottom = math.Max(bottom, c.Cell.Y+c.Cell.H)
+		}
+		return bottom, report
+	}
+	with, _ := gridBottom(false)
+	without, report := gridBottom(true)
+
+	footer, noFooter := testOptions(), testOptions()
+	noFooter.NoFooter = true
+	grown := footer.bottomMargin() - noFooter.bottomMargin()
+	if report.Footer != (rect{}) {
+		t.Error("-no-footer still drew the footer QR")
+	}
+	if math.Abs(without-with-grown) > 0.5 {
+		t.Errorf("-no-footer grid ends at %.1fpx, want %.1fpx (%.1fpx lower than with the footer)", without, with+grown, grown)
+	}
+	if without > report.Page.H {
+		t.Errorf("-no-footer grid ends at %.1fpx, past the %.0fpx page", without, report.Page.H)
+	}
+	if err := checkReport(report, len(report.Cells)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFooterBounds(t *testing.T) {
+	quietLog(t)
+	pages := []struct {
+		name string
+		w, h float64 // inches
+	}{
+		{"A4", a4WidthInches, a4HeightInches},
+		{"Letter", 8.5, 11},
+		{"card", 3.5, 2},
+		{"strip", 4, 0.5},
+	}
+	cmds := []GitCmd{{Code: "git status"}}
+	for _, page := range pages {
+		for _, dpi := range []float64{72, 150} {
+			for _, scale := range []float64{1, 4, 20} {
+				t.Run(fmt.Sprintf("%s/%gdpi/scale%g", page.name, dpi, scale), func(t *testing.T) {
+					opts := testOptions()
+					opts.PageWidthIn, opts.PageHeightIn, opts.DPI, opts.Scale = page.w, page.h, dpi, scale
+					_, report, err := renderSheet(cmds, opts)
+					if err != nil {
+						t.Fatal(err)
+					}
+					w, h := opts.pagePx()
+					size := footerQRSize(w, opts)
+					_, fits := footerQRTop(float64(h), opts.px(60), size, opts)
+					f := report.Footer
+					switch {
+					case f != (rect{}) && !report.Page.contains(f):
+						t.Errorf("footer QR %+v falls off the %dx%dpx page", f, w, h)
+					case f == (rect{}) && fits && page.name == "A4" && scale == 1:
+						t.Error("footer QR left off")
+					case f != (rect{}) && !fits:
+						t.Error("footer QR drawn where it can't fit")
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestTemplateHeaderStrip(t *testing.T) {
+	quietLog(t)
+	tests := []struct {
+		template   string
+		topMM      float64 // 0 keeps the template's own
+		wantHeader bool
+	}{
+		{"avery5160", 0, true},
+		{"l7160", 0, true},
+		{"avery5160", 2, false}, // too little margin for a readable QR
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%gmm", tt.template, tt.topMM), func(t *testing.T) {
+			tmpl, err := lookupTemplate(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.topMM > 0 {
+				tmpl.TopMM = tt.topMM
+			}
+			opts := testOptions()
+			opts.Template, opts.RepeatFooterAsHeader = &tmpl, true
+			opts.PageWidthIn, opts.PageHeightIn = tmpl.PageWidthMM/inch, tmpl.PageHeightMM/inch
+			pages := sheetPages(builtinCommands(t), opts)
+			if len(pages) < 2 {
+				t.Fatalf("%d page(s), want a multi-page sheet", len(pages))
+			}
+			for i, page := range pages {
+				dc, report, err := renderSheet(page, opts.onPage(i))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := checkReport(report, len(page)); err != nil {
+					t.Errorf("page %d: %v", i+1, err)
+				}
+				h := report.Header
+				switch {
+				case i == 0 && h != (rect{}):
+					t.Errorf("page 1 has a header strip %+v", h)
+				case i > 0 && tt.wantHeader && h == (rect{}):
+					t.Errorf("page %d has no header strip", i+1)
+				case i > 0 && !tt.wantHeader && h != (rect{}):
+					t.Errorf("page %d has a header strip %+v in a %gmm margin", i+1, h, tmpl.TopMM)
+				case h != (rect{}):
+					if res, err := decodeQR(dc.Image(), h); err != nil || res.GetText() != opts.FooterURL {
+						t.Errorf("page %d header QR does not decode: %v", i+1, err)
+					}
+					if top := mmToPx(tmpl.TopMM, opts.DPI); h.Y+h.H > top {
+						t.Errorf("page %d header QR ends at %.0fpx, below the %.0fpx top margin", i+1, h.Y+h.H, top)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDescColumnKeepsBarcodes(t *testing.T) {
+	quietLog(t)
+	tests := []struct {
+		name    string
+		width   float64
+		wantErr bool
+	}{
+		{"default width", 0.5, false},
+		{"wide column", 0.7, false},
+		{"no room", 0.95, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions()
+			opts.DescColumn, opts.DescColumnWidth = true, tt.width
+			cmds := builtinCommands(t)
+			_, report, err := renderSheet(cmds, opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("rendered barcodes that can't fit beside their descriptions")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := checkReport(report, len(cmds)); err != nil {
+				t.Error(err)
+			}
+			for _, cell := range report.Cells {
+				if _, ok := cell.Elements[cellBarcode]; !ok {
+					t.Errorf("%q has no barcode", cell.Cmd.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestListPractice(t *testing.T) {
+	quietLog(t)
+	opts := testOptions()
+	opts.Layout, opts.Practice = layoutList, true
+	cmds := sheetPages(builtinCommands(t), opts)[0]
+	_, report, err := renderSheet(cmds, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkReport(report, len(cmds)); err != nil {
+		t.Error(err)
+	}
+	for _, cell := range report.Cells {
+		box, ok := cell.Elements[cellCheckbox]
+		switch {
+		case !ok:
+			t.Errorf("%q row has no practice checkbox", cell.Cmd.Code)
+		case !cell.Cell.contains(box):
+			t.Errorf("%q checkbox %+v outside its row %+v", cell.Cmd.Code, box, cell.Cell)
+		case box.overlaps(cell.Elements[cellLabel]) || box.overlaps(cell.Elements[cellBarcode]):
+			t.Errorf("%q checkbox %+v runs into the label or barcode", cell.Cmd.Code, box)
+		}
+	}
+}
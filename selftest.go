@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// selfTest renders cmds with opts and fails, naming the offending commands, if
+// any was skipped or drawn outside its cell or the page. It is a smoke check
+// of the user's own configuration; the features themselves are covered by
+// go test.
+func selfTest(cmds []GitCmd, opts Options) error {
+	var errs []error
+	for i, page := range sheetPages(cmds, opts) {
+		_, report, err := renderSheet(page, opts.onPage(i))
+		if err != nil {
+			return err
+		}
+		errs = append(errs, checkReport(report, len(page)))
+	}
+	return errors.Join(errs...)
+}
+
+// checkReport fails if any of the n commands on a rendered sheet was skipped
+// or drawn outside its cell or the page.
+func checkReport(report *renderReport, n int) error {
+	var errs []error
+	for _, cell := range report.Cells {
+		if cell.Err != nil {
+			errs = append(errs, fmt.Errorf("%q skipped: %w", cell.Cmd.Code, cell.Err))
+			continue
+		}
+		if b, ok := cell.Elements[cellBarcode]; ok && !cell.Cell.contains(b) {
+			errs = append(errs, fmt.Errorf("%q barcode %+v spills out of its cell %+v", cell.Cmd.Code, b, cell.Cell))
+		}
+		for _, el := range defaultCellOrder {
+			if b, ok := cell.Elements[el]; ok && !report.Page.contains(b) {
+				errs = append(errs, fmt.Errorf("%q %s %+v falls off the page", cell.Cmd.Code, el, b))
+			}
+		}
+	}
+	if f := report.Footer; f != (rect{}) && !report.Page.contains(f) {
+		errs = append(errs, fmt.Errorf("footer QR %+v falls off the page", f))
+	}
+	if h := report.Header; h != (rect{}) {
+		if !report.Page.contains(h) {
+			errs = append(errs, fmt.Errorf("header QR %+v falls off the page", h))
+		}
+		for _, cell := range report.Cells {
+			if cell.Cell.overlaps(h) {
+				errs = append(errs, fmt.Errorf("header QR %+v runs into the cell of %q", h, cell.Cmd.Code))
+			}
+		}
+	}
+	if l := report.Legend; l != (rect{}) && (!report.Page.contains(l) || l.overlaps(report.Footer)) {
+		errs = append(errs, fmt.Errorf("symbology legend %+v falls off the page or over the footer QR", l))
+	}
+	for _, m := range report.CropMarks {
+		for _, cell := range report.Cells {
+			if cell.Cell.overlaps(m) {
+				errs = append(errs, fmt.Errorf("crop mark %+v runs into the cell of %q", m, cell.Cmd.Code))
+			}
+		}
+		if !report.Page.contains(m) {
+			errs = append(errs, fmt.Errorf("crop mark %+v falls off the page", m))
+		}
+	}
+	if n != len(report.Cells) {
+		errs = append(errs, fmt.Errorf("rendered %d of %d commands", len(report.Cells), n))
+	}
+	return errors.Join(errs...)
+}
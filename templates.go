@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// labelTemplate is the geometry of a sheet of peel-off labels. Each label is
+// one cell; the title and footer are left off so nothing lands between labels.
+type labelTemplate struct {
+	Name          string  `json:"name"`
+	PageWidthMM   float64 `json:"page_width_mm"`
+	PageHeightMM  float64 `json:"page_height_mm"`
+	Cols          int     `json:"cols"`
+	Rows          int     `json:"rows"`
+	LabelWidthMM  float64 `json:"label_width_mm"`
+	LabelHeightMM float64 `json:"label_height_mm"`
+	TopMM         float64 `json:"top_mm"`  // page top to the first row
+	LeftMM        float64 `json:"left_mm"` // page left to the first column
+	// Distance between the left (top) edges of neighbouring labels; zero
+	// means labels touch, i.e. the label width (height).
+	HPitchMM float64 `json:"h_pitch_mm,omitempty"`
+	VPitchMM float64 `json:"v_pitch_mm,omitempty"`
+}
+
+// inch is one inch in millimetres, for the US templates.
+const inch = 25.4
+
+// labelTemplates are the built-in templates selectable with -template.
+var labelTemplates = map[string]labelTemplate{
+	"avery5160": {
+		Name: "avery5160", PageWidthMM: 8.5 * inch, PageHeightMM: 11 * inch, Cols: 3, Rows: 10,
+		LabelWidthMM: 2.625 * inch, LabelHeightMM: 1 * inch, TopMM: 0.5 * inch, LeftMM: 0.1875 * inch,
+		HPitchMM: 2.75 * inch,
+	},
+	"avery5163": {
+		Name: "avery5163", PageWidthMM: 8.5 * inch, PageHeightMM: 11 * inch, Cols: 2, Rows: 5,
+		LabelWidthMM: 4 * inch, LabelHeightMM: 2 * inch, TopMM: 0.5 * inch, LeftMM: 0.15625 * inch,
+		HPitchMM: 4.1875 * inch,
+	},
+	"l7160": {
+		Name: "l7160", PageWidthMM: 210, PageHeightMM: 297, Cols: 3, Rows: 7,
+		LabelWidthMM: 63.5, LabelHeightMM: 38.1, TopMM: 15.15, LeftMM: 7.25,
+		HPitchMM: 66.04,
+	},
+	"l7163": {
+		Name: "l7163", PageWidthMM: 210, PageHeightMM: 297, Cols: 2, Rows: 7,
+		LabelWidthMM: 99.1, LabelHeightMM: 38.1, TopMM: 15.15, LeftMM: 4.65,
+		HPitchMM: 101.6,
+	},
+}
+
+// loadTemplates adds the templates in a JSON array file to labelTemplates,
+// replacing built-ins of the same name.
+func loadTemplates(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var tmpls []labelTemplate
+	if err := json.Unmarshal(data, &tmpls); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, t := range tmpls {
+		if err := t.validate(); err != nil {
+			return fmt.Errorf("%s: entry %d: %w", path, i+1, err)
+		}
+		labelTemplates[t.Name] = t
+	}
+	return nil
+}
+
+// lookupTemplate returns the template with the given name.
+func lookupTemplate(name string) (labelTemplate, error) {
+	if t, ok := labelTemplates[name]; ok {
+		return t, nil
+	}
+	var names []string
+	for n := range labelTemplates {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return labelTemplate{}, fmt.Errorf("unknown template %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// validate checks that t describes labels that fit on its page.
+func (t labelTemplate) validate() error {
+	switch {
+	case t.Name == "":
+		return fmt.Errorf("template has no name")
+	case t.Cols < 1 || t.Rows < 1:
+		return fmt.Errorf("template %q needs at least one column and row", t.Name)
+	case t.LabelWidthMM <= 0 || t.LabelHeightMM <= 0 || t.PageWidthMM <= 0 || t.PageHeightMM <= 0:
+		return fmt.Errorf("template %q needs positive page and label sizes", t.Name)
+	}
+	if right := t.LeftMM + float64(t.Cols-1)*t.hPitch() + t.LabelWidthMM; right > t.PageWidthMM+0.5 {
+		return fmt.Errorf("template %q labels end %.1fmm past the page width", t.Name, right-t.PageWidthMM)
+	}
+	if bottom := t.TopMM + float64(t.Rows-1)*t.vPitch() + t.LabelHeightMM; bottom > t.PageHeightMM+0.5 {
+		return fmt.Errorf("template %q labels end %.1fmm past the page height", t.Name, bottom-t.PageHeightMM)
+	}
+	return nil
+}
+
+func (t labelTemplate) hPitch() float64 {
+	if t.HPitchMM > 0 {
+		return t.HPitchMM
+	}
+	return t.LabelWidthMM
+}
+
+func (t labelTemplate) vPitch() float64 {
+	if t.VPitchMM > 0 {
+		return t.VPitchMM
+	}
+	return t.LabelHeightMM
+}
+
+// labels is the number of labels on one sheet.
+func (t labelTemplate) labels() int {
+	return t.Cols * t.Rows
+}
+
+// drawTemplate draws up to t.labels() commands, one per label, row by row in
+// opts.FillOrder.
+func drawTemplate(dc canvas, cmds []GitCmd, t labelTemplate, opts Options, report *renderReport) error {
+	w, h := mmToPx(t.LabelWidthMM, opts.DPI), mmToPx(t.LabelHeightMM, opts.DPI)
+	for i, cmd := range cmds[:min(len(cmds), t.labels())] {
+		x := mmToPx(t.LeftMM+float64(opts.fillColumn(i, t.Cols))*t.hPitch(), opts.DPI)
+		y := mmToPx(t.TopMM+float64(i/t.Cols)*t.vPitch(), opts.DPI)
+		boxes, err := opts.drawCell(dc, cmd, rect{x, y, w, h})
+		if errors.Is(err, errCellOverflow) {
+			return err
+		}
+		if err != nil {
+			log.Printf("Barcode error for %q: %v", cmd.Code, err)
+		}
+		report.Cells = append(report.Cells, cellReport{Cmd: cmd, Cell: rect{x, y, w, h}, Elements: boxes, Err: err})
+	}
+	return nil
+}
+
+// onPage returns o for drawing sheet page i, counting from 0, of those
+// sheetPages returns.
+func (o Options) onPage(i int) Options {
+	o.page = i
+	return o
+}
+
+// sheetPages splits cmds into the commands of each sheet page: all on one
+// page, or a template's worth of labels per page.
+func sheetPages(cmds []GitCmd, opts Options) [][]GitCmd {
+	if opts.Template == nil {
+		return [][]GitCmd{cmds}
+	}
+	var pages [][]GitCmd
+	for n := opts.Template.labels(); len(cmds) > 0; {
+		k := min(n, len(cmds))
+		pages = append(pages, cmds[:k])
+		cmds = cmds[k:]
+	}
+	return pages
+}
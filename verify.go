@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg" // photos are usually JPEG
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/makiuchi-d/gozxing"
+	multiqr "github.com/makiuchi-d/gozxing/multi/qrcode"
+	"github.com/makiuchi-d/gozxing/oned"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// photoResult is whether one expected command was read from a photo.
+type photoResult struct {
+	Cmd   GitCmd
+	Page  int // 1-based sheet page the command is laid out on
+	Found bool
+	Photo string      // the photo it was read from, when Found
+	At    image.Point // where in that photo it was read, when Found
+}
+
+// verifyPhoto decodes the barcodes in photos of a printed sheet and reports,
+// for each of cmds, whether it was read from any of them. Each photo is
+// assumed to show one whole page roughly square on, in any order: the cells
+// of every page as laid out by opts are mapped onto it and decoded on their
+// own, then any QR codes still missing are looked for across the whole photo.
+func verifyPhoto(paths []string, cmds []GitCmd, opts Options) ([]photoResult, error) {
+	var results []photoResult
+	var cells []rect
+	var page rect
+	for i, cmds := range sheetPages(cmds, opts) {
+		_, report, err := renderSheet(cmds, opts.onPage(i))
+		if err != nil {
+			return nil, err
+		}
+		page = report.Page
+		for _, cell := range report.Cells {
+			results = append(results, photoResult{Cmd: cell.Cmd, Page: i + 1})
+			cells = append(cells, cell.Cell)
+		}
+	}
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		photo, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", path, err)
+		}
+		bmp, err := gozxing.NewBinaryBitmapFromImage(photo)
+		if err != nil {
+			return nil, err
+		}
+		pb := photo.Bounds()
+		sx, sy := float64(pb.Dx())/page.W, float64(pb.Dy())/page.H
+
+		var whole map[string]image.Point // lazily decoded QR codes across the photo
+		for i := range results {
+			r := &results[i]
+			if r.Found {
+				continue
+			}
+			raw, err := encodeRaw(r.Cmd, opts)
+			if err != nil {
+				continue
+			}
+			want := raw.Content()
+
+			// The cell, grown by a tenth on each side to allow for a skewed photo.
+			c := cells[i]
+			x0 := max(int((c.X-c.W/10)*sx), 0)
+			y0 := max(int((c.Y-c.H/10)*sy), 0)
+			x1 := min(int(math.Ceil((c.X+c.W*1.1)*sx)), pb.Dx())
+			y1 := min(int(math.Ceil((c.Y+c.H*1.1)*sy)), pb.Dy())
+			if crop, err := bmp.Crop(x0, y0, x1-x0, y1-y0); err == nil {
+				var reader gozxing.Reader = qrcode.NewQRCodeReader()
+				if opts.isCode128(r.Cmd) {
+					reader = oned.NewCode128Reader()
+				}
+				if res, err := reader.Decode(crop, hints); err == nil && decodedContent(res, r.Cmd) == want {
+					r.Found, r.Photo = true, path
+					r.At = resultCenter(res).Add(image.Pt(x0, y0))
+					continue
+				}
+			}
+
+			if !opts.isCode128(r.Cmd) {
+				if whole == nil {
+					whole = map[string]image.Point{}
+					found, _ := multiqr.NewQRCodeMultiReader().DecodeMultiple(bmp, hints)
+					for _, res := range found {
+						whole[res.GetText()] = resultCenter(res)
+						whole[decodedContent(res, GitCmd{Base64: true})] = resultCenter(res)
+					}
+				}
+				if at, ok := whole[want]; ok {
+					r.Found, r.Photo, r.At = true, path, at
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+// decodedContent is what a decoded barcode holds in the terms encodeRaw's
+// Content uses: the raw bytes of a Base64 payload, which gozxing's text would
+// reinterpret as ISO-8859-1 or UTF-8, else the text.
+func decodedContent(res *gozxing.Result, cmd GitCmd) string {
+	if !cmd.Base64 {
+		return res.GetText()
+	}
+	segments, _ := res.GetResultMetadata()[gozxing.ResultMetadataType_BYTE_SEGMENTS].([][]byte)
+	var data []byte
+	for _, s := range segments {
+		data = append(data, s...)
+	}
+	return string(data)
+}
+
+// resultCenter is the average of a decode result's points.
+func resultCenter(res *gozxing.Result) image.Point {
+	pts := res.GetResultPoints()
+	if len(pts) == 0 {
+		return image.Point{}
+	}
+	var x, y float64
+	for _, p := range pts {
+		x += p.GetX()
+		y += p.GetY()
+	}
+	n := float64(len(pts))
+	return image.Pt(int(x/n), int(y/n))
+}
+
+// printPhotoResults writes one line per expected command and returns how many
+// could not be read.
+func printPhotoResults(w io.Writer, results []photoResult) (unreadable int, err error) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, r := range results {
+		if r.Found {
+			fmt.Fprintf(tw, "ok\tpage %d\t%s (%d,%d)\t%q\n", r.Page, filepath.Base(r.Photo), r.At.X, r.At.Y, r.Cmd.Code)
+		} else {
+			unreadable++
+			fmt.Fprintf(tw, "UNREADABLE\tpage %d\t\t%q\n", r.Page, r.Cmd.Code)
+		}
+	}
+	fmt.Fprintf(tw, "\n%d of %d commands read\n", len(results)-unreadable, len(results))
+	return unreadable, tw.Flush()
+}
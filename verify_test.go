@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyPhotoPages(t *testing.T) {
+	quietLog(t)
+	tmpl, err := lookupTemplate("avery5163")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions()
+	opts.Template = &tmpl
+	opts.PageWidthIn, opts.PageHeightIn = tmpl.PageWidthMM/inch, tmpl.PageHeightMM/inch
+	cmds := builtinCommands(t)
+	pages := sheetPages(cmds, opts)
+	if len(pages) < 3 {
+		t.Fatalf("%d page(s), want at least 3", len(pages))
+	}
+
+	// Photograph pages 2 and 3, given out of order; page 1 and the rest are
+	// left out.
+	dir := t.TempDir()
+	var photos []string
+	for _, i := range []int{2, 1} {
+		dc, _, err := renderSheet(pages[i], opts.onPage(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("page%d.png", i+1))
+		if err := saveImage(path, dc.Image(), opts); err != nil {
+			t.Fatal(err)
+		}
+		photos = append(photos, path)
+	}
+
+	results, err := verifyPhoto(photos, cmds, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(cmds) {
+		t.Fatalf("%d results for %d commands", len(results), len(cmds))
+	}
+	n := 0
+	for i, page := range pages {
+		for _, cmd := range page {
+			r := results[n]
+			n++
+			photographed := i == 1 || i == 2
+			switch {
+			case r.Cmd.Code != cmd.Code || r.Page != i+1:
+				t.Errorf("result %q on page %d, want %q on page %d", r.Cmd.Code, r.Page, cmd.Code, i+1)
+			case r.Found != photographed:
+				t.Errorf("page %d %q read: %v, want %v", i+1, cmd.Code, r.Found, photographed)
+			case r.Found && r.Photo != filepath.Join(dir, fmt.Sprintf("page%d.png", i+1)):
+				t.Errorf("page %d %q read from %s", i+1, cmd.Code, r.Photo)
+			}
+		}
+	}
+}
+
+func TestVerifyPhotoBase64(t *testing.T) {
+	quietLog(t)
+	opts := testOptions()
+	opts.Cols = 2
+	cmds := []GitCmd{
+		{Code: "git status"},
+		// Bytes that are not valid UTF-8, so the text gozxing decodes differs.
+		{Code: base64.StdEncoding.EncodeToString([]byte{0, 'g', 'i', 't', 0xff, 0x80, 0xc3}), Label: "payload", Base64: true},
+		{Code: base64.StdEncoding.EncodeToString([]byte("plain ascii payload")), Label: "ascii", Base64: true},
+	}
+	dc, _, err := renderSheet(cmds, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "sheet.png")
+	if err := saveImage(path, dc.Image(), opts); err != nil {
+		t.Fatal(err)
+	}
+	results, err := verifyPhoto([]string{path}, cmds, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if !r.Found {
+			t.Errorf("%q (base64 %v) reported unreadable", r.Cmd.Code, r.Cmd.Base64)
+		}
+	}
+}